@@ -0,0 +1,141 @@
+package mirango
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/mirango/framework"
+)
+
+// codecField is an exported struct field as named by the codecs.
+type codecField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// codecFields returns the fields of a struct type, named after the given
+// tag, or the json tag, or their names. The fields of embedded structs
+// without a name are promoted.
+func codecFields(t reflect.Type, tag string) []codecField {
+	var fields []codecField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitEmpty, ok := codecFieldName(f, tag)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, ef := range codecFields(f.Type, tag) {
+				ef.index = append([]int{i}, ef.index...)
+				fields = append(fields, ef)
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, codecField{
+			name:      name,
+			index:     []int{i},
+			omitEmpty: omitEmpty,
+		})
+	}
+	return fields
+}
+
+func codecFieldName(f reflect.StructField, tag string) (string, bool, bool) {
+	for _, tn := range []string{tag, "json"} {
+		t, ok := f.Tag.Lookup(tn)
+		if !ok {
+			continue
+		}
+		if t == "-" {
+			return "", false, false
+		}
+		parts := strings.Split(t, ",")
+		omitEmpty := false
+		for _, o := range parts[1:] {
+			if o == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		return parts[0], omitEmpty, true
+	}
+	return "", false, true
+}
+
+// findCodecField returns the field named name, or named so regardless of
+// case.
+func findCodecField(fields []codecField, name string) (codecField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return codecField{}, false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// Decode decodes the body of the request into v with the decoder registered
+// for its content type, or as JSON, XML, CSV or MessagePack.
+func (c *Context) Decode(v interface{}) error {
+	mimeType := c.requestEncoding
+	if mimeType == "" {
+		mimeType, _, _ = mime.ParseMediaType(c.Request.Header.Get(framework.HEADER_ContentType))
+	}
+	b, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	return decode(c.decoders, b, v, mimeType)
+}
+
+func decode(decoders framework.Decoders, b []byte, v interface{}, mimeType string) error {
+	if decoder := decoders.Get(mimeType); decoder != nil {
+		return decoder.Decode(b, v)
+	}
+
+	_, subtype := parseMediaType(mimeType)
+	switch {
+	case subtype == "json" || strings.HasSuffix(subtype, "+json"):
+		return json.Unmarshal(b, v)
+	case subtype == "xml" || strings.HasSuffix(subtype, "+xml"):
+		return xml.Unmarshal(b, v)
+	case subtype == "csv":
+		return CSVCodec{}.Decode(b, v)
+	case subtype == "msgpack" || subtype == "x-msgpack":
+		return unmarshalMsgpack(b, v)
+	}
+	return fmt.Errorf("no decoder found for %s", mimeType)
+}
@@ -0,0 +1,267 @@
+package mirango
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression configures the compression of responses.
+type Compression struct {
+	// Level is the compression level, as defined by compress/flate.
+	Level int
+	// MinSize is the size under which bodies are not compressed.
+	MinSize int
+	// SkipTypes are the media types that are not compressed, such as images
+	// that are already compressed. A type ending with "/" matches all its
+	// subtypes.
+	SkipTypes []string
+}
+
+// DefaultCompression returns the compression used if none is configured.
+func DefaultCompression() *Compression {
+	return &Compression{
+		Level:   flate.DefaultCompression,
+		MinSize: 1024,
+		SkipTypes: []string{
+			"image/",
+			"video/",
+			"audio/",
+			"font/woff",
+			"font/woff2",
+			"application/zip",
+			"application/gzip",
+			"application/x-gzip",
+			"application/x-bzip2",
+			"application/x-7z-compressed",
+			"application/x-rar-compressed",
+			MIME_EVENT_STREAM,
+		},
+	}
+}
+
+func (cp *Compression) skips(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+	for _, t := range cp.SkipTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateCompression returns "gzip", "deflate" or "" from the
+// Accept-Encoding header of the request. gzip is preferred on ties.
+func negotiateCompression(r *http.Request) string {
+	best := ""
+	bestQ := 0.0
+	wildcard := -1.0
+	qs := map[string]float64{}
+	for _, hv := range ParseHeaderValues(r.Header.Values("Accept-Encoding")) {
+		q := 1.0
+		if v, ok := hv.Param("q"); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		coding := strings.ToLower(hv.Value)
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		qs[coding] = q
+	}
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qs[coding]
+		if !ok {
+			if wildcard < 0 {
+				continue
+			}
+			q = wildcard
+		}
+		if q > bestQ {
+			best = coding
+			bestQ = q
+		}
+	}
+	return best
+}
+
+// compressWriter compresses what is written to it once it knows the body is
+// large enough and of a compressible type.
+type compressWriter struct {
+	http.ResponseWriter
+	cp       *Compression
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	disabled bool
+	hijacked bool
+	zw       io.WriteCloser
+}
+
+func newCompressWriter(w http.ResponseWriter, encoding string, cp *Compression) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: w,
+		cp:             cp,
+		encoding:       encoding,
+	}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	if status == http.StatusNotModified {
+		// the client may hold the compressed representation
+		weakenETag(w.Header())
+	}
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent {
+		w.disabled = true
+		w.decide(false)
+	}
+}
+
+// weakenETag makes the ETag weak, as it was computed for the identity body.
+func weakenETag(h http.Header) {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+// decide writes the header, compressing the body if possible. small reports
+// whether the whole body is known to be under the minimum size.
+func (w *compressWriter) decide(small bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if !w.disabled && !small && h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && !w.cp.skips(h.Get("Content-Type")) {
+		if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+			h.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		var err error
+		switch w.encoding {
+		case "gzip":
+			w.zw, err = gzip.NewWriterLevel(w.ResponseWriter, w.cp.Level)
+		case "deflate":
+			w.zw, err = flate.NewWriter(w.ResponseWriter, w.cp.Level)
+		}
+		if err != nil {
+			return err
+		}
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		weakenETag(h)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.zw != nil {
+		return w.zw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.disabled {
+			err := w.decide(false)
+			if err != nil {
+				return 0, err
+			}
+			return w.write(b)
+		}
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.cp.MinSize {
+			return len(b), nil
+		}
+		return len(b), w.decide(false)
+	}
+	return w.write(b)
+}
+
+// Flush writes the buffered body, compressed if possible, as a flushed body
+// is considered a stream whatever its size.
+func (w *compressWriter) Flush() {
+	if w.decide(false) != nil {
+		return
+	}
+	if fw, ok := w.zw.(interface{ Flush() error }); ok {
+		fw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+	w.hijacked = true
+	return hj.Hijack()
+}
+
+func (w *compressWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+// Close writes what remains of the body.
+func (w *compressWriter) Close() error {
+	if w.hijacked {
+		return nil
+	}
+	err := w.decide(len(w.buf) < w.cp.MinSize)
+	if err != nil {
+		return err
+	}
+	if w.zw != nil {
+		return w.zw.Close()
+	}
+	return nil
+}
+
+// disable stops the response from being compressed, unless it already is.
+func (w *compressWriter) disable() {
+	w.disabled = true
+}
+
+// disableCompression stops the response from being compressed, unless some
+// of it has already been written compressed.
+func (w *Response) disableCompression() {
+	if cw, ok := w.ResponseWriter.(*compressWriter); ok {
+		cw.disable()
+	}
+}
@@ -0,0 +1,197 @@
+package mirango
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateCompression(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP", "gzip"},
+		{"br", ""},
+		{"*", "gzip"},
+		{"gzip;q=0, *", "deflate"},
+		{"gzip;q=0, deflate;q=0, *", ""},
+		{"identity", ""},
+		{"gzip;q=x, deflate", "deflate"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		if got := negotiateCompression(r); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func decompress(t *testing.T, encoding string, r io.Reader) string {
+	switch encoding {
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	case "deflate":
+		r = flate.NewReader(r)
+	}
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCompressWriter(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+	tests := []struct {
+		name        string
+		encoding    string
+		header      http.Header
+		status      int
+		body        string
+		compressed  bool
+		contentType string
+		etag        string
+	}{
+		{"gzip", "gzip", nil, 200, large, true, "text/plain; charset=utf-8", ""},
+		{"deflate", "deflate", nil, 200, large, true, "text/plain; charset=utf-8", ""},
+		{"small", "gzip", nil, 200, "small", false, "", ""},
+		{"status", "gzip", nil, 201, large, true, "text/plain; charset=utf-8", ""},
+		{"skipped type", "gzip", http.Header{"Content-Type": {"image/png"}}, 200, large, false, "image/png", ""},
+		{"svg", "gzip", http.Header{"Content-Type": {"image/svg+xml"}}, 200, large, true, "image/svg+xml", ""},
+		{"already encoded", "gzip", http.Header{"Content-Encoding": {"br"}}, 200, large, false, "", ""},
+		{"weakened etag", "gzip", http.Header{"Etag": {`"a"`}}, 200, large, true, "text/plain; charset=utf-8", `W/"a"`},
+		{"weak etag", "gzip", http.Header{"Etag": {`W/"a"`}}, 200, large, true, "text/plain; charset=utf-8", `W/"a"`},
+		{"identity etag", "gzip", http.Header{"Etag": {`"a"`}}, 200, "small", false, "", `"a"`},
+		{"not modified", "gzip", http.Header{"Etag": {`"a"`}}, 304, "", false, "", `W/"a"`},
+		{"no content", "gzip", nil, 204, "", false, "", ""},
+		{"partial content", "gzip", http.Header{"Content-Range": {"bytes 0-9/100"}}, 206, large, false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			for k, v := range tt.header {
+				rec.Header()[k] = v
+			}
+			rec.Header().Set("Content-Length", "1")
+			w := newCompressWriter(rec, tt.encoding, DefaultCompression())
+			w.WriteHeader(tt.status)
+			// written in two parts to go through the buffer
+			io.WriteString(w, tt.body[:len(tt.body)/2])
+			io.WriteString(w, tt.body[len(tt.body)/2:])
+			err := w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			encoding := rec.Header().Get("Content-Encoding")
+			if tt.compressed != (encoding == tt.encoding) {
+				t.Errorf("Content-Encoding %q", encoding)
+			}
+			if tt.compressed && rec.Header().Get("Content-Length") != "" {
+				t.Error("Content-Length is set")
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type %q, want %q", rec.Header().Get("Content-Type"), tt.contentType)
+			}
+			if got := rec.Header().Get("Etag"); got != tt.etag {
+				t.Errorf("ETag %q, want %q", got, tt.etag)
+			}
+			if !tt.compressed {
+				encoding = ""
+			}
+			if got := decompress(t, encoding, rec.Body); got != tt.body {
+				t.Errorf("body %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestCompressWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newCompressWriter(rec, "gzip", DefaultCompression())
+	io.WriteString(w, "data: a\n\n")
+	w.Flush()
+	if !rec.Flushed || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("flushed %v, Content-Encoding %q", rec.Flushed, rec.Header().Get("Content-Encoding"))
+	}
+	io.WriteString(w, "data: b\n\n")
+	w.Close()
+	if got := decompress(t, "gzip", rec.Body); got != "data: a\n\ndata: b\n\n" {
+		t.Errorf("body %q", got)
+	}
+}
+
+type closeNotifyRecorder struct {
+	*httptest.ResponseRecorder
+	closed chan bool
+}
+
+func (r *closeNotifyRecorder) CloseNotify() <-chan bool {
+	return r.closed
+}
+
+func TestCompressWriterCloseNotify(t *testing.T) {
+	rec := &closeNotifyRecorder{httptest.NewRecorder(), make(chan bool)}
+	w := NewResponse(newCompressWriter(rec, "gzip", DefaultCompression()), nil)
+	if w.CloseNotify() != (<-chan bool)(rec.closed) {
+		t.Error("the channel of the ResponseWriter is not returned")
+	}
+
+	w = NewResponse(newCompressWriter(httptest.NewRecorder(), "gzip", DefaultCompression()), nil)
+	if w.CloseNotify() != nil {
+		t.Error("a channel is returned for a ResponseWriter that is not a CloseNotifier")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+	m := New("/")
+	m.Compression(nil)
+	m.Branch("large").GET(func(c *Context) interface{} { return large })
+	m.Branch("none").GET(func(c *Context) interface{} { return large }).NoCompression()
+	m.Prepare()
+
+	tests := []struct {
+		path       string
+		accept     string
+		compressed bool
+	}{
+		{"/large", "gzip", true},
+		{"/large", "", false},
+		{"/none", "gzip", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.path, nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, r)
+		encoding := rec.Header().Get("Content-Encoding")
+		if tt.compressed != (encoding == "gzip") {
+			t.Errorf("%s %q: Content-Encoding %q", tt.path, tt.accept, encoding)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s %q: Vary %q", tt.path, tt.accept, rec.Header().Get("Vary"))
+		}
+		if got := decompress(t, encoding, rec.Body); got != large {
+			t.Errorf("%s %q: body %q", tt.path, tt.accept, got)
+		}
+	}
+}
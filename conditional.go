@@ -0,0 +1,181 @@
+package mirango
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type etagMode int
+
+const (
+	etagNone etagMode = iota
+	etagStrong
+	etagWeak
+)
+
+// ETags makes Render compute an ETag for the bodies it writes and answer
+// conditional GET and HEAD requests with 304 Not Modified.
+func (m *Mirango) ETags(weak bool) {
+	if weak {
+		m.etags = etagWeak
+	} else {
+		m.etags = etagStrong
+	}
+}
+
+func computeETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	tag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// FormatETag quotes a version as an entity tag.
+func FormatETag(version string, weak bool) string {
+	tag := `"` + strings.Replace(version, `"`, "", -1) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header matches
+// etag. "*" matches any current representation, even without an ETag.
+func etagMatches(header string, etag string, strong bool) bool {
+	for _, t := range splitHeaderValues([]string{header}) {
+		if t == "*" {
+			return true
+		}
+		if etag == "" {
+			continue
+		}
+		if strong {
+			if !strings.HasPrefix(t, "W/") && !strings.HasPrefix(etag, "W/") && t == etag {
+				return true
+			}
+		} else if strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	return method == "GET" || method == "HEAD"
+}
+
+// evaluatePreconditions evaluates the conditional headers of the request
+// against the ETag and Last-Modified headers of the response, in the order
+// defined by RFC 7232. It returns 304, 412 or 0 if the request can proceed.
+func evaluatePreconditions(r *http.Request, h http.Header) int {
+	etag := h.Get("ETag")
+	lastModified, err := http.ParseTime(h.Get("Last-Modified"))
+	hasLastModified := err == nil
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !etagMatches(im, etag, true) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && hasLastModified {
+		t, err := http.ParseTime(ius)
+		if err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag, false) {
+			if isSafeMethod(r.Method) {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && hasLastModified && isSafeMethod(r.Method) {
+		t, err := http.ParseTime(ims)
+		if err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// setValidators sets the ETag and Last-Modified headers, unless empty.
+func setValidators(h http.Header, etag string, lastModified time.Time) {
+	if etag != "" {
+		h.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		h.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// CheckPreconditions evaluates the conditional headers of the request
+// against the validators of the current state of the resource. It returns a
+// 304 or 412 Responder for the handler to return, or nil if the request can
+// proceed. Unsafe operations should call it before changing anything. The
+// validators are set on the response of GET and HEAD requests only, as
+// unsafe operations change the resource they describe.
+func (c *Context) CheckPreconditions(etag string, lastModified time.Time) Responder {
+	h := http.Header{}
+	if isSafeMethod(c.Request.Request.Method) {
+		h = c.Response.Header()
+	}
+	setValidators(h, etag, lastModified)
+	status := evaluatePreconditions(c.Request.Request, h)
+	if status == 0 {
+		return nil
+	}
+	return Respond(status, nil)
+}
+
+// Conditional sets a function returning the validators of the resource,
+// such as its version and its update time. The conditional headers of the
+// request are evaluated before the handler runs, answering with 304 or 412.
+func (o *Operation) Conditional(f func(*Context) (etag string, lastModified time.Time)) *Operation {
+	o.conditional = f
+	return o
+}
+
+func CheckPreconditions(o *Operation) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(c *Context) interface{} {
+			if o.conditional == nil {
+				return next.ServeHTTP(c)
+			}
+			etag, lastModified := o.conditional(c)
+			if r := c.CheckPreconditions(etag, lastModified); r != nil {
+				return r
+			}
+			return next.ServeHTTP(c)
+		})
+	})
+}
+
+// notModified computes the ETag of a body about to be written if needed,
+// and writes 304 Not Modified if the request is satisfied by the
+// representation the client has.
+func (w *Response) notModified(status int, b []byte) bool {
+	if w.request == nil || !isSafeMethod(w.request.Method) || (status != 0 && status != http.StatusOK) {
+		return false
+	}
+	h := w.Header()
+	if h.Get("ETag") == "" && w.etags != etagNone {
+		h.Set("ETag", computeETag(b, w.etags == etagWeak))
+	}
+	if h.Get("ETag") == "" && h.Get("Last-Modified") == "" {
+		return false
+	}
+	if evaluatePreconditions(w.request, h) != http.StatusNotModified {
+		return false
+	}
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,122 @@
+package mirango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	after := modTime.Add(time.Hour).Format(http.TimeFormat)
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		etag   string
+		want   int
+	}{
+		{"no conditions", "GET", nil, `"a"`, 0},
+		{"if-match", "PUT", http.Header{"If-Match": {`"b", "a"`}}, `"a"`, 0},
+		{"if-match mismatch", "PUT", http.Header{"If-Match": {`"b"`}}, `"a"`, 412},
+		{"if-match weak", "PUT", http.Header{"If-Match": {`W/"a"`}}, `"a"`, 412},
+		{"if-match any", "PUT", http.Header{"If-Match": {"*"}}, "", 0},
+		{"if-unmodified-since", "PUT", http.Header{"If-Unmodified-Since": {after}}, "", 0},
+		{"if-unmodified-since modified", "PUT", http.Header{"If-Unmodified-Since": {before}}, "", 412},
+		{"if-match over if-unmodified-since", "PUT", http.Header{"If-Match": {`"a"`}, "If-Unmodified-Since": {before}}, `"a"`, 0},
+		{"if-none-match", "GET", http.Header{"If-None-Match": {`W/"a"`}}, `"a"`, 304},
+		{"if-none-match mismatch", "GET", http.Header{"If-None-Match": {`"b"`}}, `"a"`, 0},
+		{"if-none-match unsafe", "PUT", http.Header{"If-None-Match": {"*"}}, `"a"`, 412},
+		{"if-modified-since", "GET", http.Header{"If-Modified-Since": {after}}, "", 304},
+		{"if-modified-since modified", "GET", http.Header{"If-Modified-Since": {before}}, "", 0},
+		{"if-modified-since unsafe", "PUT", http.Header{"If-Modified-Since": {after}}, "", 0},
+		{"if-none-match over if-modified-since", "GET", http.Header{"If-None-Match": {`"b"`}, "If-Modified-Since": {after}}, `"a"`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			h := http.Header{}
+			setValidators(h, tt.etag, modTime)
+			if got := evaluatePreconditions(r, h); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionalOperation(t *testing.T) {
+	version := 1
+	current := func(c *Context) (string, time.Time) {
+		return FormatETag("v"+strconv.Itoa(version), false), time.Time{}
+	}
+	m := New("/")
+	doc := m.Branch("doc")
+	doc.GET(func(c *Context) interface{} { return "doc" }).Conditional(current)
+	doc.PUT(func(c *Context) interface{} {
+		version++
+		return "updated"
+	}).Conditional(current)
+	doc.DELETE(func(c *Context) interface{} {
+		if r := c.CheckPreconditions(current(c)); r != nil {
+			return r
+		}
+		return nil
+	})
+	m.Prepare()
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		status int
+		etag   string
+	}{
+		{"get", "GET", nil, 200, `"v1"`},
+		{"get not modified", "GET", http.Header{"If-None-Match": {`"v1"`}}, 304, `"v1"`},
+		{"put", "PUT", http.Header{"If-Match": {`"v1"`}}, 200, ""},
+		{"put lost update", "PUT", http.Header{"If-Match": {`"v1"`}}, 412, ""},
+		{"get updated", "GET", http.Header{"If-None-Match": {`"v1"`}}, 200, `"v2"`},
+		{"delete precondition failed", "DELETE", http.Header{"If-Match": {`"v1"`}}, 412, ""},
+		{"delete", "DELETE", http.Header{"If-Match": {`"v2"`}}, 204, ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/doc", nil)
+		for k, v := range tt.header {
+			r.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, r)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if got := rec.Header().Get("ETag"); got != tt.etag {
+			t.Errorf("%s: ETag %q, want %q", tt.name, got, tt.etag)
+		}
+	}
+}
+
+func TestConditionalETags(t *testing.T) {
+	m := New("/")
+	m.ETags(false)
+	m.Branch("doc").PUT(func(c *Context) interface{} {
+		if r := c.CheckPreconditions(`"old"`, time.Time{}); r != nil {
+			return r
+		}
+		return "new"
+	})
+	m.Prepare()
+
+	r := httptest.NewRequest("PUT", "/doc", nil)
+	r.Header.Set("If-Match", `"old"`)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, r)
+	if rec.Code != 200 || rec.Header().Get("ETag") == `"old"` {
+		t.Errorf("got %d with ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+}
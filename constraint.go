@@ -0,0 +1,237 @@
+package mirango
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mirango/framework"
+	"github.com/mirango/validation"
+)
+
+const (
+	FORMAT_EMAIL     = "email"
+	FORMAT_UUID      = "uuid"
+	FORMAT_DATE_TIME = "date-time"
+)
+
+var uuidRegexp = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// constraints holds the declarative validation metadata of a Param.
+type constraints struct {
+	enum      []string
+	min       *float64
+	max       *float64
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+	format    string
+}
+
+// Enum restricts the values of the Param to the given ones.
+func (p *Param) Enum(values ...interface{}) *Param {
+	p.constraints.enum = nil
+	for _, v := range values {
+		p.constraints.enum = append(p.constraints.enum, fmt.Sprint(v))
+	}
+	return p
+}
+
+func (p *Param) GetEnum() []string {
+	return p.constraints.enum
+}
+
+// Min sets the minimum numeric value of the Param.
+func (p *Param) Min(min float64) *Param {
+	p.constraints.min = &min
+	return p
+}
+
+func (p *Param) GetMin() (float64, bool) {
+	if p.constraints.min == nil {
+		return 0, false
+	}
+	return *p.constraints.min, true
+}
+
+// Max sets the maximum numeric value of the Param.
+func (p *Param) Max(max float64) *Param {
+	p.constraints.max = &max
+	return p
+}
+
+func (p *Param) GetMax() (float64, bool) {
+	if p.constraints.max == nil {
+		return 0, false
+	}
+	return *p.constraints.max, true
+}
+
+// MinLength sets the minimum length, in characters, of the Param.
+func (p *Param) MinLength(min int) *Param {
+	p.constraints.minLength = &min
+	return p
+}
+
+func (p *Param) GetMinLength() (int, bool) {
+	if p.constraints.minLength == nil {
+		return 0, false
+	}
+	return *p.constraints.minLength, true
+}
+
+// MaxLength sets the maximum length, in characters, of the Param.
+func (p *Param) MaxLength(max int) *Param {
+	p.constraints.maxLength = &max
+	return p
+}
+
+func (p *Param) GetMaxLength() (int, bool) {
+	if p.constraints.maxLength == nil {
+		return 0, false
+	}
+	return *p.constraints.maxLength, true
+}
+
+// Pattern sets the regular expression the Param has to match.
+func (p *Param) Pattern(pattern interface{}) *Param {
+	switch t := pattern.(type) {
+	case *regexp.Regexp:
+		p.constraints.pattern = t
+	case string:
+		p.constraints.pattern = regexp.MustCompile(t)
+	default:
+		panic("invalid pattern")
+	}
+	return p
+}
+
+func (p *Param) GetPattern() *regexp.Regexp {
+	return p.constraints.pattern
+}
+
+// Format sets the well-known format of the Param.
+// Supported formats are "email", "uuid" and "date-time".
+func (p *Param) Format(format string) *Param {
+	switch format {
+	case FORMAT_EMAIL, FORMAT_UUID, FORMAT_DATE_TIME:
+		p.constraints.format = format
+	default:
+		panic(fmt.Sprintf("invalid format: \"%s\"", format))
+	}
+	return p
+}
+
+func (p *Param) GetFormat() string {
+	return p.constraints.format
+}
+
+// constraintValidators returns the built-in validators enforcing the
+// declared constraints.
+func (p *Param) constraintValidators() []validation.Validator {
+	var validators []validation.Validator
+	cs := p.constraints
+	if len(cs.enum) > 0 {
+		validators = append(validators, EnumValidator(cs.enum...))
+	}
+	if cs.min != nil || cs.max != nil {
+		validators = append(validators, RangeValidator(cs.min, cs.max))
+	}
+	if cs.minLength != nil || cs.maxLength != nil {
+		validators = append(validators, LengthValidator(cs.minLength, cs.maxLength))
+	}
+	if cs.pattern != nil {
+		validators = append(validators, PatternValidator(cs.pattern))
+	}
+	if cs.format != "" {
+		validators = append(validators, FormatValidator(cs.format))
+	}
+	return validators
+}
+
+type constraintValidator func(framework.Context, string) error
+
+// Validate runs the check against every value of v.
+func (f constraintValidator) Validate(c framework.Context, v framework.ParamValue) error {
+	if v == nil {
+		return nil
+	}
+	for _, s := range v.Strings() {
+		err := f(c, s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func EnumValidator(values ...string) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		if containsString(values, s) {
+			return nil
+		}
+		return newError(c, MSG_ENUM, strings.Join(values, ", "))
+	})
+}
+
+func RangeValidator(min *float64, max *float64) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return newError(c, MSG_NUMBER)
+		}
+		if min != nil && f < *min {
+			return newError(c, MSG_MIN, *min)
+		}
+		if max != nil && f > *max {
+			return newError(c, MSG_MAX, *max)
+		}
+		return nil
+	})
+}
+
+func LengthValidator(min *int, max *int) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		l := utf8.RuneCountInString(s)
+		if min != nil && l < *min {
+			return newError(c, MSG_MIN_LENGTH, *min)
+		}
+		if max != nil && l > *max {
+			return newError(c, MSG_MAX_LENGTH, *max)
+		}
+		return nil
+	})
+}
+
+func PatternValidator(pattern *regexp.Regexp) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		if !pattern.MatchString(s) {
+			return newError(c, MSG_PATTERN, pattern.String())
+		}
+		return nil
+	})
+}
+
+func FormatValidator(format string) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		var ok bool
+		switch format {
+		case FORMAT_EMAIL:
+			_, err := mail.ParseAddress(s)
+			ok = err == nil
+		case FORMAT_UUID:
+			ok = uuidRegexp.MatchString(s)
+		case FORMAT_DATE_TIME:
+			_, err := time.Parse(time.RFC3339, s)
+			ok = err == nil
+		}
+		if !ok {
+			return newError(c, MSG_FORMAT, format)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,104 @@
+package mirango
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// Content is a seekable body returned by handlers. Render serves it with
+// support for single and multipart Range requests, If-Range, conditional
+// requests and 416 Range Not Satisfiable.
+type Content struct {
+	name        string
+	modTime     time.Time
+	content     io.ReadSeeker
+	contentType string
+	disposition string
+	filename    string
+}
+
+// ServeContent returns a Content. The name is used to detect the content
+// type, and modTime, if not zero, to set Last-Modified.
+func ServeContent(name string, modTime time.Time, content io.ReadSeeker) *Content {
+	return &Content{
+		name:    name,
+		modTime: modTime,
+		content: content,
+	}
+}
+
+// ServeFile returns the Content of an open file, named and dated after it.
+func ServeFile(f *os.File) *Content {
+	c := ServeContent(f.Name(), time.Time{}, f)
+	if fi, err := f.Stat(); err == nil {
+		c.modTime = fi.ModTime()
+	}
+	return c
+}
+
+// Attachment makes clients download the content, under the given filename or
+// the base of its name.
+func (c *Content) Attachment(filename ...string) *Content {
+	c.disposition = "attachment"
+	if len(filename) > 0 {
+		c.filename = filename[0]
+	}
+	return c
+}
+
+// Inline makes clients display the content.
+func (c *Content) Inline(filename ...string) *Content {
+	c.disposition = "inline"
+	if len(filename) > 0 {
+		c.filename = filename[0]
+	}
+	return c
+}
+
+// ContentType sets the content type. Otherwise, the Content-Type header set
+// by the handler is kept, or the type is detected.
+func (c *Content) ContentType(contentType string) *Content {
+	c.contentType = contentType
+	return c
+}
+
+// serve writes the content with the status given by the Range and
+// conditional headers of the request. A Responder can only return it with a
+// 2xx status, that is replaced.
+func (c *Content) serve(w *Response, status int) error {
+	if closer, ok := c.content.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if status != 0 && (status < 200 || status > 299) {
+		return fmt.Errorf("content cannot be served with status %d", status)
+	}
+	w.disableCompression()
+
+	h := w.Header()
+	if c.contentType != "" {
+		h.Set("Content-Type", c.contentType)
+	}
+	if c.disposition != "" {
+		filename := c.filename
+		if filename == "" && c.name != "" {
+			filename = path.Base(c.name)
+		}
+		params := map[string]string{}
+		if filename != "" {
+			params["filename"] = filename
+		}
+		h.Set("Content-Disposition", mime.FormatMediaType(c.disposition, params))
+	}
+
+	if w.request == nil {
+		_, err := io.Copy(w, c.content)
+		return err
+	}
+	http.ServeContent(w, w.request, c.name, c.modTime, c.content)
+	return nil
+}
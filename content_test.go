@@ -0,0 +1,179 @@
+package mirango
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func renderTestContent(t *testing.T, header http.Header, data interface{}) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/", nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	w := NewResponse(rec, nil)
+	w.request = r
+	c := NewContext(w, NewRequest(r))
+	if err := w.Render(c, data); err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestRenderContent(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name         string
+		data         interface{}
+		header       http.Header
+		status       int
+		body         string
+		contentType  string
+		lastModified string
+	}{
+		{"content", ServeContent("a.txt", modTime, strings.NewReader("hello")), nil, 200, "hello", "text/plain; charset=utf-8", modTime.Format(http.TimeFormat)},
+		{"content without name", ServeContent("", time.Time{}, strings.NewReader("<html></html>")), nil, 200, "<html></html>", "text/html; charset=utf-8", ""},
+		{"read seeker", strings.NewReader("hello"), nil, 200, "hello", "text/plain; charset=utf-8", ""},
+		{"range", ServeContent("a.txt", modTime, strings.NewReader("0123456789")), http.Header{"Range": {"bytes=2-4"}}, 206, "234", "text/plain; charset=utf-8", modTime.Format(http.TimeFormat)},
+		{"read seeker range", strings.NewReader("0123456789"), http.Header{"Range": {"bytes=-3"}}, 206, "789", "text/plain; charset=utf-8", ""},
+		{"unsatisfiable range", strings.NewReader("0123456789"), http.Header{"Range": {"bytes=20-"}}, 416, "", "", ""},
+		{"if-range mismatch", ServeContent("a.txt", modTime, strings.NewReader("0123456789")), http.Header{"Range": {"bytes=0-1"}, "If-Range": {"Mon, 01 Jan 2001 00:00:00 GMT"}}, 200, "0123456789", "text/plain; charset=utf-8", modTime.Format(http.TimeFormat)},
+		{"not modified", ServeContent("a.txt", modTime, strings.NewReader("hello")), http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}, 304, "", "", modTime.Format(http.TimeFormat)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := renderTestContent(t, tt.header, tt.data)
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status != 416 && rec.Body.String() != tt.body {
+				t.Errorf("body %q, want %q", rec.Body.String(), tt.body)
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("Content-Type %q, want %q", rec.Header().Get("Content-Type"), tt.contentType)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != tt.lastModified {
+				t.Errorf("Last-Modified %q, want %q", got, tt.lastModified)
+			}
+		})
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.json")
+	err := os.WriteFile(name, []byte(`{"a":1}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = os.Chtimes(name, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attachment := range []bool{false, true} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data interface{} = f
+		if attachment {
+			data = ServeFile(f).Attachment()
+		}
+		rec := renderTestContent(t, nil, data)
+		if rec.Code != 200 || rec.Body.String() != `{"a":1}` {
+			t.Errorf("got %d %q", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type %q", got)
+		}
+		if got := rec.Header().Get("Last-Modified"); got != modTime.Format(http.TimeFormat) {
+			t.Errorf("Last-Modified %q", got)
+		}
+		want := ""
+		if attachment {
+			want = "attachment; filename=data.json"
+		}
+		if got := rec.Header().Get("Content-Disposition"); got != want {
+			t.Errorf("Content-Disposition %q, want %q", got, want)
+		}
+		// the file is closed once served
+		if _, err := f.Stat(); err == nil {
+			t.Error("file is not closed")
+		}
+	}
+}
+
+func TestContentHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     *Content
+		disposition string
+		contentType string
+	}{
+		{"attachment", ServeContent("dir/report.xml", time.Time{}, strings.NewReader("<a/>")).Attachment(), "attachment; filename=report.xml", "text/xml; charset=utf-8"},
+		{"attachment filename", ServeContent("report.xml", time.Time{}, strings.NewReader("<a/>")).Attachment("export.xml"), "attachment; filename=export.xml", "text/xml; charset=utf-8"},
+		{"inline quoted filename", ServeContent("x", time.Time{}, strings.NewReader("{}")).Inline("a b.json"), `inline; filename="a b.json"`, "text/plain; charset=utf-8"},
+		{"attachment without name", ServeContent("", time.Time{}, strings.NewReader("a")).Attachment(), "attachment", "text/plain; charset=utf-8"},
+		{"content type", ServeContent("a.txt", time.Time{}, strings.NewReader("{}")).ContentType("application/json"), "", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := renderTestContent(t, nil, tt.content)
+			if got := rec.Header().Get("Content-Disposition"); got != tt.disposition {
+				t.Errorf("Content-Disposition %q, want %q", got, tt.disposition)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type %q, want %q", got, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestContentNotCompressed(t *testing.T) {
+	m := New("/")
+	m.Compression(nil)
+	m.Branch("content").GET(func(c *Context) interface{} {
+		return ServeContent("a.txt", time.Time{}, strings.NewReader(strings.Repeat("a", 5000)))
+	})
+	m.Prepare()
+
+	r := httptest.NewRequest("GET", "/content", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Range", "bytes=0-9")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, r)
+	if rec.Code != 206 || rec.Body.String() != "aaaaaaaaaa" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding %q", got)
+	}
+}
+
+func TestContentResponder(t *testing.T) {
+	content := func() *Content {
+		return ServeContent("a.txt", time.Time{}, strings.NewReader("{}"))
+	}
+
+	rec := renderTestContent(t, nil, Respond(200, content()).Header("Content-Type", "application/json"))
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("got %d with Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = renderTestContent(t, nil, Respond(200, content().ContentType("text/plain")).Header("Content-Type", "application/json"))
+	if rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+
+	w := NewResponse(httptest.NewRecorder(), nil)
+	w.request = httptest.NewRequest("GET", "/", nil)
+	if err := w.Render(NewContext(w, NewRequest(w.request)), NotFound(content())); err == nil {
+		t.Error("content rendered with 404")
+	}
+}
@@ -16,7 +16,15 @@ type Context struct {
 	values    framework.Values
 	user      framework.User
 	locale    framework.Locale
-	ended     bool
+	language  string
+	catalog   *Catalog
+	types     *ValueTypes
+	decoders  framework.Decoders
+	templates *Templates
+	page      *Page
+
+	requestEncoding string
+	ended           bool
 }
 
 func NewContext(res *Response, req *Request) *Context {
@@ -59,6 +67,12 @@ func (c *Context) Value(k interface{}) interface{} {
 	return c.values[k]
 }
 
+// RequestEncoding returns the media type of the request body, as matched
+// against the accepts of the operation.
+func (c *Context) RequestEncoding() string {
+	return c.requestEncoding
+}
+
 func (c *Context) Header() http.Header {
 	return c.Response.Header()
 }
@@ -66,3 +80,10 @@ func (c *Context) Header() http.Header {
 func (c *Context) End() {
 	c.ended = true
 }
+
+func (c *Context) getProblemRenderer() ProblemRenderer {
+	if c.route == nil {
+		return nil
+	}
+	return c.route.GetProblemRenderer()
+}
@@ -0,0 +1,64 @@
+package mirango
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// SignCookieValue returns value followed by its HMAC-SHA256 signature, in the
+// format expected by signed cookie params.
+func SignCookieValue(value string, key []byte) string {
+	return value + "." + cookieSignature(value, key)
+}
+
+// VerifyCookieValue checks the signature of a value produced by
+// SignCookieValue against the given keys and returns the original value.
+func VerifyCookieValue(signed string, keys ...[]byte) (string, bool) {
+	i := strings.LastIndex(signed, ".")
+	if i == -1 {
+		return "", false
+	}
+	value, sig := signed[:i], signed[i+1:]
+	for _, key := range keys {
+		if hmac.Equal([]byte(sig), []byte(cookieSignature(value, key))) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func cookieSignature(value string, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// getCookieValues returns the values of the cookies of a param, looked up by
+// its name then its aliases, and the name used. It returns false if the
+// signature of a value is invalid.
+func getCookieValues(c *Context, p *Param) ([]string, string, bool) {
+	cookies := c.Cookies()
+	for _, name := range p.names() {
+		var values []string
+		for _, cookie := range cookies {
+			if cookie.Name != name {
+				continue
+			}
+			v := cookie.Value
+			if p.IsSigned() {
+				var ok bool
+				v, ok = VerifyCookieValue(v, p.signingKeys...)
+				if !ok {
+					return nil, name, false
+				}
+			}
+			values = append(values, v)
+		}
+		if len(values) > 0 {
+			return values, name, true
+		}
+	}
+	return nil, "", true
+}
@@ -0,0 +1,334 @@
+package mirango
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const MIME_CSV = "text/csv"
+
+// CSVCodec encodes slices of structs or maps as CSV, with a header row
+// naming the columns after the csv tags of the fields, or their json tags.
+// Nested slices, maps and structs are written as JSON. It decodes CSV with a
+// header row into a pointer to such a slice.
+type CSVCodec struct {
+	// Comma is the field delimiter, ',' if not set.
+	Comma rune
+}
+
+func (cc CSVCodec) MimeType() string {
+	return MIME_CSV
+}
+
+func (cc CSVCodec) comma() rune {
+	if cc.Comma == 0 {
+		return ','
+	}
+	return cc.Comma
+}
+
+func (cc CSVCodec) Encode(v interface{}) ([]byte, error) {
+	rows := csvRows(reflect.ValueOf(v))
+	var t reflect.Type
+	if rv := reflect.ValueOf(v); rv.IsValid() {
+		t = csvRowType(rv.Type())
+	}
+	cols, err := newCSVColumns(t, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = cc.comma()
+	err = w.Write(cols.names)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		err = w.Write(cols.record(row))
+		if err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func (cc CSVCodec) Decode(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("csv: cannot decode into %T, a pointer to a slice is needed", v)
+	}
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = cc.comma()
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	s := rv.Elem()
+	rows := reflect.MakeSlice(s.Type(), 0, len(records))
+	if len(records) == 0 {
+		s.Set(rows)
+		return nil
+	}
+	header := records[0]
+	et := s.Type().Elem()
+	for _, record := range records[1:] {
+		row := reflect.New(et).Elem()
+		err = setCSVRow(header, record, row)
+		if err != nil {
+			return err
+		}
+		rows = reflect.Append(rows, row)
+	}
+	s.Set(rows)
+	return nil
+}
+
+// csvRows returns the rows of v: the elements of a slice or array, or v
+// itself.
+func csvRows(v reflect.Value) []reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return []reflect.Value{v}
+	}
+	rows := make([]reflect.Value, v.Len())
+	for i := range rows {
+		rows[i] = v.Index(i)
+	}
+	return rows
+}
+
+// csvRowType returns the type of the rows of a value of type t.
+func csvRowType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+type csvColumns struct {
+	names  []string
+	fields []codecField
+}
+
+// newCSVColumns returns the columns of rows of type t. The columns of maps
+// are their keys, in order, and the columns of rows of interface type those
+// of the first row.
+func newCSVColumns(t reflect.Type, rows []reflect.Value) (*csvColumns, error) {
+	if (t == nil || t.Kind() == reflect.Interface) && len(rows) > 0 {
+		first := rows[0]
+		for first.Kind() == reflect.Ptr || first.Kind() == reflect.Interface {
+			if first.IsNil() {
+				break
+			}
+			first = first.Elem()
+		}
+		t = first.Type()
+	}
+	if t == nil {
+		return &csvColumns{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		cols := &csvColumns{fields: codecFields(t, "csv")}
+		for _, f := range cols.fields {
+			cols.names = append(cols.names, f.name)
+		}
+		return cols, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			break
+		}
+		keys := map[string]bool{}
+		for _, row := range rows {
+			row = reflect.Indirect(row)
+			if row.Kind() == reflect.Interface {
+				row = reflect.Indirect(row.Elem())
+			}
+			if row.Kind() != reflect.Map {
+				continue
+			}
+			for _, k := range row.MapKeys() {
+				keys[k.String()] = true
+			}
+		}
+		cols := &csvColumns{}
+		for k := range keys {
+			cols.names = append(cols.names, k)
+		}
+		sort.Strings(cols.names)
+		return cols, nil
+	}
+	return nil, fmt.Errorf("csv: cannot encode rows of type %s", t)
+}
+
+// record returns the fields of a row.
+func (cols *csvColumns) record(row reflect.Value) []string {
+	for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+		if row.IsNil() {
+			return make([]string, len(cols.names))
+		}
+		row = row.Elem()
+	}
+	record := make([]string, len(cols.names))
+	switch row.Kind() {
+	case reflect.Struct:
+		if cols.fields == nil {
+			return record
+		}
+		for i, f := range cols.fields {
+			record[i] = formatCSVValue(row.FieldByIndex(f.index))
+		}
+	case reflect.Map:
+		for i, name := range cols.names {
+			record[i] = formatCSVValue(row.MapIndex(reflect.ValueOf(name).Convert(row.Type().Key())))
+		}
+	}
+	return record
+}
+
+func formatCSVValue(v reflect.Value) string {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err == nil {
+			return string(b)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+		b, err := json.Marshal(v.Interface())
+		if err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// setCSVRow sets the fields of a struct, or the entries of a map, from a
+// record.
+func setCSVRow(header []string, record []string, row reflect.Value) error {
+	for row.Kind() == reflect.Ptr {
+		if row.IsNil() {
+			row.Set(reflect.New(row.Type().Elem()))
+		}
+		row = row.Elem()
+	}
+
+	switch row.Kind() {
+	case reflect.Struct:
+		fields := codecFields(row.Type(), "csv")
+		for i, name := range header {
+			if i >= len(record) {
+				break
+			}
+			f, ok := findCodecField(fields, name)
+			if !ok {
+				continue
+			}
+			err := setCSVValue(record[i], row.FieldByIndex(f.index))
+			if err != nil {
+				return fmt.Errorf("csv: column %q: %s", name, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		if row.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if row.IsNil() {
+			row.Set(reflect.MakeMap(row.Type()))
+		}
+		for i, name := range header {
+			if i >= len(record) {
+				break
+			}
+			ev := reflect.New(row.Type().Elem()).Elem()
+			err := setCSVValue(record[i], ev)
+			if err != nil {
+				return fmt.Errorf("csv: column %q: %s", name, err)
+			}
+			row.SetMapIndex(reflect.ValueOf(name).Convert(row.Type().Key()), ev)
+		}
+		return nil
+	}
+	return fmt.Errorf("csv: cannot decode rows into %s", row.Type())
+}
+
+func setCSVValue(str string, dst reflect.Value) error {
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		dst.Set(reflect.ValueOf(str))
+		return nil
+	}
+	if str == "" && dst.Kind() != reflect.String {
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return setCSVValue(str, dst.Elem())
+	}
+	if u, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(str))
+	}
+	switch dst.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes([]byte(str))
+			return nil
+		}
+		return json.Unmarshal([]byte(str), dst.Addr().Interface())
+	}
+	return assignString(str, dst)
+}
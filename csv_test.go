@@ -0,0 +1,134 @@
+package mirango
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type csvAddress struct {
+	City string `json:"city"`
+}
+
+type csvBase struct {
+	ID int `csv:"id"`
+}
+
+type csvUser struct {
+	csvBase
+	Name      string     `csv:"name" json:"full_name"`
+	Email     string     `json:"email"`
+	Age       *int       `json:"age"`
+	Admin     bool       `json:"admin"`
+	Score     float64    `json:"score"`
+	CreatedAt time.Time  `json:"created_at"`
+	Tags      []string   `json:"tags"`
+	Address   csvAddress `json:"address"`
+	Secret    string     `json:"-"`
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	age := 30
+	created := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		name string
+		in   interface{}
+		csv  string
+	}{
+		{
+			"structs",
+			[]csvUser{
+				{csvBase{1}, "Ann, \"A\"", "ann@x.io", &age, true, 1.5, created, []string{"a", "b"}, csvAddress{"Paris"}, ""},
+				{csvBase{2}, "Bob\nJr", "", nil, false, 0, time.Time{}, nil, csvAddress{}, ""},
+			},
+			"id,name,email,age,admin,score,created_at,tags,address\n" +
+				"1,\"Ann, \"\"A\"\"\",ann@x.io,30,true,1.5,2020-01-02T03:04:05.000000006Z,\"[\"\"a\"\",\"\"b\"\"]\",\"{\"\"city\"\":\"\"Paris\"\"}\"\n" +
+				"2,\"Bob\nJr\",,,false,0,0001-01-01T00:00:00Z,null,\"{\"\"city\"\":\"\"\"\"}\"\n",
+		},
+		{
+			"maps",
+			[]map[string]string{{"b": "2", "a": "1"}, {"a": "3", "b": ""}},
+			"a,b\n1,2\n3,\n",
+		},
+		{
+			"empty",
+			[]csvUser{},
+			"id,name,email,age,admin,score,created_at,tags,address\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := CSVCodec{}.Encode(tt.in)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if string(b) != tt.csv {
+				t.Errorf("encoded %q, want %q", b, tt.csv)
+			}
+			out := reflect.New(reflect.TypeOf(tt.in))
+			err = CSVCodec{}.Decode(b, out.Interface())
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(out.Elem().Interface(), tt.in) {
+				t.Errorf("decoded %#v, want %#v", out.Elem().Interface(), tt.in)
+			}
+		})
+	}
+}
+
+func TestCSVComma(t *testing.T) {
+	cc := CSVCodec{Comma: ';'}
+	b, err := cc.Encode([]map[string]string{{"a": "1,2", "b": "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a;b\n1,2;3\n" {
+		t.Errorf("encoded %q", b)
+	}
+}
+
+func TestCSVDecodeColumns(t *testing.T) {
+	var users []*csvUser
+	err := CSVCodec{}.Decode([]byte("NAME,unknown,id\nAnn,x,1\n"), &users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].Name != "Ann" || users[0].ID != 1 {
+		t.Errorf("decoded %#v", users)
+	}
+}
+
+func TestCSVEncodeUnsupported(t *testing.T) {
+	for _, in := range []interface{}{[]int{1}, []map[int]string{{1: "a"}}} {
+		if _, err := (CSVCodec{}).Encode(in); err == nil {
+			t.Errorf("no error encoding %#v", in)
+		}
+	}
+}
+
+func TestCSVDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		v    interface{}
+	}{
+		{"bare quote", "id,name\n1,An\"n\n", new([]csvUser)},
+		{"unterminated quote", "id,name\n1,\"Ann\n", new([]csvUser)},
+		{"wrong field count", "id,name\n1\n", new([]csvUser)},
+		{"invalid int", "id\nx\n", new([]csvUser)},
+		{"invalid bool", "admin\nmaybe\n", new([]csvUser)},
+		{"invalid time", "created_at\nyesterday\n", new([]csvUser)},
+		{"invalid json", "tags\n[\n", new([]csvUser)},
+		{"non-pointer", "id\n1\n", []csvUser{}},
+		{"pointer to non-slice", "id\n1\n", new(csvUser)},
+		{"unsupported rows", "id\n1\n", new([]int)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (CSVCodec{}).Decode([]byte(tt.in), tt.v); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package mirango
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type paramStyle int
+
+const (
+	STYLE_FORM paramStyle = iota
+	STYLE_DEEP_OBJECT
+)
+
+// parseDeepObject collects the query keys of the form name[a][b] and name[]
+// into nested maps and slices. Repeated keys are collected into slices. The
+// keys are read in sorted order, and the plain name key is ignored if
+// bracketed keys are present. It also returns the members of the object in
+// that order, and false if no key belongs to the param.
+func parseDeepObject(q url.Values, name string) (interface{}, []string, bool) {
+	var keys []string
+	bracketed := false
+	for key := range q {
+		if key == name {
+			keys = append(keys, key)
+		} else if strings.HasPrefix(key, name+"[") {
+			if _, ok := splitDeepKey(key[len(name):]); ok {
+				keys = append(keys, key)
+				bracketed = true
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, false
+	}
+	sort.Strings(keys)
+
+	var root interface{}
+	var members []string
+	for _, key := range keys {
+		if key == name && bracketed {
+			continue
+		}
+		segs, _ := splitDeepKey(key[len(name):])
+		root = setDeepValue(root, segs, q[key])
+		members = append(members, q[key]...)
+	}
+	return root, members, true
+}
+
+// splitDeepKey splits "[a][b][]" into ["a", "b", ""].
+func splitDeepKey(key string) ([]string, bool) {
+	var segs []string
+	for len(key) > 0 {
+		if key[0] != '[' {
+			return nil, false
+		}
+		end := strings.IndexByte(key, ']')
+		if end == -1 {
+			return nil, false
+		}
+		segs = append(segs, key[1:end])
+		key = key[end+1:]
+	}
+	return segs, true
+}
+
+func setDeepValue(cur interface{}, segs []string, values []string) interface{} {
+	if len(segs) == 0 {
+		if cur == nil && len(values) == 1 {
+			return values[0]
+		}
+		var arr []interface{}
+		switch t := cur.(type) {
+		case []interface{}:
+			arr = t
+		case nil:
+		default:
+			arr = []interface{}{t}
+		}
+		for _, v := range values {
+			arr = append(arr, v)
+		}
+		return arr
+	}
+
+	if segs[0] == "" {
+		arr, _ := cur.([]interface{})
+		if len(segs) == 1 {
+			for _, v := range values {
+				arr = append(arr, v)
+			}
+			return arr
+		}
+		return append(arr, setDeepValue(nil, segs[1:], values))
+	}
+
+	m, ok := cur.(map[string]interface{})
+	if !ok {
+		m = map[string]interface{}{}
+	}
+	m[segs[0]] = setDeepValue(m[segs[0]], segs[1:], values)
+	return m
+}
+
+// bindDeepValue assigns a value produced by parseDeepObject to dst, which
+// must be a pointer. Struct fields are matched by their "param" or "json" tag,
+// or by their name, case-insensitively.
+func bindDeepValue(src interface{}, dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot bind to non-pointer value (%T)", dst)
+	}
+	return assignDeepValue(src, rv.Elem())
+}
+
+func assignDeepValue(src interface{}, dst reflect.Value) error {
+	if src == nil {
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignDeepValue(src, dst.Elem())
+	case reflect.Interface:
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot bind %T to %s", src, dst.Type())
+		}
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" || f.Tag.Get("param") == "-" || f.Tag.Get("json") == "-" {
+				continue
+			}
+			v, ok := m[deepFieldName(f)]
+			if !ok {
+				for k, kv := range m {
+					if strings.EqualFold(k, f.Name) {
+						v, ok = kv, true
+						break
+					}
+				}
+			}
+			if !ok {
+				continue
+			}
+			err := assignDeepValue(v, dst.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		m, ok := src.(map[string]interface{})
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("cannot bind %T to %s", src, dst.Type())
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		for k, v := range m {
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			err := assignDeepValue(v, ev)
+			if err != nil {
+				return err
+			}
+			dst.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), ev)
+		}
+		return nil
+	case reflect.Slice:
+		arr, ok := src.([]interface{})
+		if !ok {
+			arr = []interface{}{src}
+		}
+		s := reflect.MakeSlice(dst.Type(), len(arr), len(arr))
+		for i, v := range arr {
+			err := assignDeepValue(v, s.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	}
+
+	str, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("cannot bind %T to %s", src, dst.Type())
+	}
+	return assignString(str, dst)
+}
+
+func assignString(str string, dst reflect.Value) error {
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(str)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, dst.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dst.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot bind string to %s", dst.Type())
+	}
+	return nil
+}
+
+func deepFieldName(f reflect.StructField) string {
+	for _, tag := range []string{"param", "json"} {
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
@@ -0,0 +1,115 @@
+package mirango
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseDeepObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    interface{}
+		members []string
+		ok      bool
+	}{
+		{"absent", "other=1", nil, nil, false},
+		{"plain", "filter=x", "x", []string{"x"}, true},
+		{"repeated plain", "filter=x&filter=y", []interface{}{"x", "y"}, []string{"x", "y"}, true},
+		{"members", "filter[b]=2&filter[a]=1", map[string]interface{}{"a": "1", "b": "2"}, []string{"1", "2"}, true},
+		{"nested", "filter[owner][id]=7", map[string]interface{}{"owner": map[string]interface{}{"id": "7"}}, []string{"7"}, true},
+		{"repeated member", "filter[a]=1&filter[a]=3", map[string]interface{}{"a": []interface{}{"1", "3"}}, []string{"1", "3"}, true},
+		{"array", "filter[]=1&filter[]=2", []interface{}{"1", "2"}, []string{"1", "2"}, true},
+		{"nested array", "filter[ids][]=1&filter[ids][]=2", map[string]interface{}{"ids": []interface{}{"1", "2"}}, []string{"1", "2"}, true},
+		{"plain ignored with members", "filter=x&filter[a]=1", map[string]interface{}{"a": "1"}, []string{"1"}, true},
+		{"other params", "filter[a]=1&filters[b]=2&filter_c=3", map[string]interface{}{"a": "1"}, []string{"1"}, true},
+		{"unclosed bracket", "filter[a=1", nil, nil, false},
+		{"text after bracket", "filter[a]b=1", nil, nil, false},
+		{"malformed keys ignored", "filter[a=1&filter[b]=2", map[string]interface{}{"b": "2"}, []string{"2"}, true},
+		{"empty value", "filter[a]=", map[string]interface{}{"a": ""}, []string{""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the query is a map, the result must not depend on its order
+			for i := 0; i < 10; i++ {
+				got, members, ok := parseDeepObject(q, "filter")
+				if ok != tt.ok || !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(members, tt.members) {
+					t.Fatalf("got %#v, %#v, %v, want %#v, %#v, %v", got, members, ok, tt.want, tt.members, tt.ok)
+				}
+			}
+		})
+	}
+}
+
+type deepFilter struct {
+	Status string            `param:"status"`
+	Owner  *deepOwner        `json:"owner"`
+	IDs    []int             `param:"ids"`
+	Labels map[string]string `param:"labels"`
+	Any    interface{}       `param:"any"`
+	Skip   string            `param:"-"`
+}
+
+type deepOwner struct {
+	ID   uint
+	Name string
+}
+
+func TestBindDeepValue(t *testing.T) {
+	q, _ := url.ParseQuery("f[status]=open&f[owner][id]=7&f[owner][NAME]=ann&f[ids][]=1&f[ids][]=2&f[labels][a]=x&f[any][k]=v&f[Skip]=x")
+	obj, _, _ := parseDeepObject(q, "f")
+	var got deepFilter
+	err := bindDeepValue(obj, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := deepFilter{
+		Status: "open",
+		Owner:  &deepOwner{ID: 7, Name: "ann"},
+		IDs:    []int{1, 2},
+		Labels: map[string]string{"a": "x"},
+		Any:    map[string]interface{}{"k": "v"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	var single []int
+	err = bindDeepValue("3", &single)
+	if err != nil || !reflect.DeepEqual(single, []int{3}) {
+		t.Errorf("got %v, %v", single, err)
+	}
+}
+
+func TestBindDeepValueErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		dst  interface{}
+	}{
+		{"non-pointer", map[string]interface{}{}, deepFilter{}},
+		{"nil pointer", "1", (*int)(nil)},
+		{"string into struct", "x", new(deepFilter)},
+		{"map into string", map[string]interface{}{"a": "1"}, new(string)},
+		{"invalid int", "x", new(int)},
+		{"overflowing int", "300", new(int8)},
+		{"negative uint", "-1", new(uint)},
+		{"invalid bool", "maybe", new(bool)},
+		{"invalid float", "1.2.3", new(float64)},
+		{"invalid member", map[string]interface{}{"ids": []interface{}{"1", "x"}}, new(deepFilter)},
+		{"non-string map keys", map[string]interface{}{"1": "a"}, new(map[int]string)},
+		{"unsupported type", "x", new(chan int)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := bindDeepValue(tt.src, tt.dst); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package mirango
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Alias adds names under which the Param is also accepted. Using an alias is
+// reported to the client as deprecated.
+func (p *Param) Alias(names ...string) *Param {
+	p.aliases = append(p.aliases, names...)
+	return p
+}
+
+func (p *Param) GetAliases() []string {
+	return p.aliases
+}
+
+// Deprecated marks the Param as deprecated. Requests using it get a
+// Deprecation header and a Warning header with the given message.
+func (p *Param) Deprecated(msg string) *Param {
+	p.isDeprecated = true
+	p.deprecation = msg
+	return p
+}
+
+func (p *Param) IsDeprecated() bool {
+	return p.isDeprecated
+}
+
+func (p *Param) GetDeprecation() string {
+	return p.deprecation
+}
+
+// names returns the name of the Param followed by its aliases.
+func (p *Param) names() []string {
+	return append([]string{p.name}, p.aliases...)
+}
+
+// lookupParam looks up the values of a param by its name then its aliases,
+// and returns the name used.
+func lookupParam(p *Param, get func(string) ([]string, bool)) ([]string, string, bool) {
+	for _, name := range p.names() {
+		if v, ok := get(name); ok {
+			return v, name, true
+		}
+	}
+	return nil, "", false
+}
+
+// warnDeprecated sets the Deprecation and Warning headers if the param is
+// deprecated or was given under one of its aliases.
+func (c *Context) warnDeprecated(p *Param, used string) {
+	var msg string
+	if used != "" && used != p.name {
+		msg = c.Message(MSG_DEPRECATED_ALIAS, used, p.name)
+	} else if p.isDeprecated {
+		msg = p.deprecation
+		if msg == "" {
+			msg = c.Message(MSG_DEPRECATED, p.name)
+		}
+	} else {
+		return
+	}
+	h := c.Response.Header()
+	h.Set("Deprecation", "true")
+	h.Add("Warning", "299 - "+strconv.Quote(msg))
+}
+
+// PrintRoutes writes the operations of the prepared Mirango, one per line,
+// with their params. Deprecated params and aliases are marked as such.
+func (m *Mirango) PrintRoutes(w io.Writer) error {
+	var lines []string
+	m.Route.walk(func(r *Route) {
+		for _, o := range r.operations.GetAll() {
+			var params []string
+			for _, p := range o.params.GetAll() {
+				params = append(params, describeParam(p))
+			}
+			sort.Strings(params)
+			line := strings.Join(o.methods, ",") + " " + r.GetFullPath()
+			if o.name != "" {
+				line += " " + o.name
+			}
+			if len(params) > 0 {
+				line += " (" + strings.Join(params, ", ") + ")"
+			}
+			lines = append(lines, line)
+		}
+	})
+	for _, line := range lines {
+		_, err := io.WriteString(w, line+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describeParam(p *Param) string {
+	s := p.name
+	for _, a := range p.aliases {
+		s += "|" + a + " [deprecated]"
+	}
+	if p.isDeprecated {
+		s += " [deprecated"
+		if p.deprecation != "" {
+			s += ": " + p.deprecation
+		}
+		s += "]"
+	}
+	return s
+}
@@ -0,0 +1,200 @@
+package mirango
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/mirango/framework"
+	"github.com/mirango/validation"
+)
+
+// Fields lets clients select the fields of the data rendered by the
+// Operation with the fields query param, such as ?fields=id,name,owner.email.
+// If selectable fields are given, only them and their sub-fields can be
+// selected. Structs are selected by the json names of their fields, and the
+// selected data is rendered as maps. As XML cannot encode maps, fields are
+// not selected when XML is negotiated. The ids of the entities rendered as
+// HAL or JSON:API documents are always selected.
+func (o *Operation) Fields(selectable ...string) *Operation {
+	o.fields = true
+	o.selectableFields = selectable
+	o.QueryParam("fields").Multiple().Validators(FieldsValidator(selectable...))
+	return o
+}
+
+// GetFields returns the selectable fields of the Operation, and whether
+// clients can select fields.
+func (o *Operation) GetFields() ([]string, bool) {
+	return o.selectableFields, o.fields
+}
+
+// FieldsValidator checks that comma-separated field paths are among the
+// selectable ones, or their sub-fields. Any field is valid if none is given.
+func FieldsValidator(selectable ...string) validation.Validator {
+	return constraintValidator(func(c framework.Context, s string) error {
+		for _, f := range splitFields([]string{s}) {
+			if !isSelectable(selectable, f) {
+				return newError(c, MSG_FIELD, f)
+			}
+		}
+		return nil
+	})
+}
+
+func isSelectable(selectable []string, field string) bool {
+	if len(selectable) == 0 {
+		return true
+	}
+	for _, s := range selectable {
+		if field == s || strings.HasPrefix(field, s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func splitFields(values []string) []string {
+	var fields []string
+	for _, v := range values {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	return fields
+}
+
+// fieldTree is a selection of fields by name. A field with no sub-fields is
+// selected whole.
+type fieldTree map[string]fieldTree
+
+func parseFieldTree(fields []string) fieldTree {
+	tree := fieldTree{}
+	for _, f := range fields {
+		t := tree
+		segs := strings.Split(f, ".")
+		for i, seg := range segs {
+			sub, ok := t[seg]
+			if ok && len(sub) == 0 {
+				// the field is already selected whole
+				break
+			}
+			if i == len(segs)-1 {
+				t[seg] = fieldTree{}
+				break
+			}
+			if !ok {
+				sub = fieldTree{}
+				t[seg] = sub
+			}
+			t = sub
+		}
+	}
+	return tree
+}
+
+// keepIDs selects the id of the objects whose fields are selected.
+func (t fieldTree) keepIDs() {
+	if len(t) == 0 {
+		return
+	}
+	for _, sub := range t {
+		sub.keepIDs()
+	}
+	if _, ok := t["id"]; !ok {
+		t["id"] = fieldTree{}
+	}
+}
+
+// selectsFields reports whether fields are selected for the given encoding,
+// as selected data is rendered as maps which XML cannot encode.
+func selectsFields(mimeType string) bool {
+	_, subtype := parseMediaType(strings.Split(mimeType, ";")[0])
+	return subtype != "xml" && !strings.HasSuffix(subtype, "+xml")
+}
+
+// selectedFields returns the fields selected by the client, or nil if the
+// operation does not let clients select fields or none are selected.
+func (c *Context) selectedFields() fieldTree {
+	if c.operation == nil || !c.operation.fields || !c.IsSet("fields") {
+		return nil
+	}
+	fields := splitFields(c.Param("fields").Strings())
+	if len(fields) == 0 {
+		return nil
+	}
+	return parseFieldTree(fields)
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// selectFields returns the selected fields of the structs and maps of data,
+// or of the elements of slices of them.
+func selectFields(data interface{}, tree fieldTree) interface{} {
+	return selectValue(reflect.ValueOf(data), tree)
+}
+
+func selectValue(v reflect.Value, tree fieldTree) interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if len(tree) == 0 || isLeafValue(v) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = selectValue(v.Index(i), tree)
+		}
+		return items
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		for _, f := range codecFields(v.Type(), "json") {
+			sub, ok := tree[f.name]
+			if !ok {
+				continue
+			}
+			m[f.name] = selectValue(v.FieldByIndex(f.index), sub)
+		}
+		return m
+	case reflect.Map:
+		m := map[string]interface{}{}
+		for name, sub := range tree {
+			mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !mv.IsValid() {
+				continue
+			}
+			m[name] = selectValue(mv, sub)
+		}
+		return m
+	}
+	return v.Interface()
+}
+
+// isLeafValue reports whether the fields of v can not be selected, as it
+// is encoded as a scalar.
+func isLeafValue(v reflect.Value) bool {
+	t := v.Type()
+	if t == timeType || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Map:
+		return t.Key().Kind() != reflect.String
+	case reflect.Struct, reflect.Array:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package mirango
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HeaderValue is an element of a comma-separated header, such as
+// "text/html;level=1;q=0.5".
+type HeaderValue struct {
+	Value  string
+	Params map[string]string
+}
+
+// Param returns the value of a parameter of the element. Parameter names
+// are case-insensitive.
+func (hv HeaderValue) Param(name string) (string, bool) {
+	v, ok := hv.Params[strings.ToLower(name)]
+	return v, ok
+}
+
+// ParseHeaderValues splits the values of a header into its comma-separated
+// elements and their semicolon-separated parameters. Quoted strings are kept
+// together.
+func ParseHeaderValues(values []string) []HeaderValue {
+	var hvs []HeaderValue
+	for _, element := range splitHeaderValues(values) {
+		parts := splitQuoted(element, ';')
+		hv := HeaderValue{
+			Value: strings.TrimSpace(parts[0]),
+		}
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if hv.Params == nil {
+				hv.Params = map[string]string{}
+			}
+			name, value := part, ""
+			if i := strings.IndexByte(part, '='); i != -1 {
+				name, value = strings.TrimSpace(part[:i]), unquote(strings.TrimSpace(part[i+1:]))
+			}
+			hv.Params[strings.ToLower(name)] = value
+		}
+		hvs = append(hvs, hv)
+	}
+	return hvs
+}
+
+// splitHeaderValues returns the non-empty comma-separated elements of the
+// values of a header.
+func splitHeaderValues(values []string) []string {
+	var elements []string
+	for _, v := range values {
+		for _, e := range splitQuoted(v, ',') {
+			e = strings.TrimSpace(e)
+			if e != "" {
+				elements = append(elements, e)
+			}
+		}
+	}
+	return elements
+}
+
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// getHeaderValues returns the values of a header, looked up by its
+// canonical name. The values of a multiple param are split on commas.
+func getHeaderValues(h http.Header, name string, multiple bool) ([]string, bool) {
+	v := h.Values(name)
+	if len(v) == 0 {
+		return nil, false
+	}
+	if multiple {
+		v = splitHeaderValues(v)
+		if len(v) == 0 {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// HeaderValues returns the parsed elements of a request header.
+func (r *Request) HeaderValues(name string) []HeaderValue {
+	return ParseHeaderValues(r.Header.Values(name))
+}
@@ -0,0 +1,392 @@
+package mirango
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gedex/inflector"
+	"github.com/mirango/framework"
+)
+
+const (
+	MIME_HAL_JSON = "application/hal+json"
+	MIME_JSON_API = "application/vnd.api+json"
+)
+
+// documentEncoder is an encoder that wraps the data rendered by the
+// operations of a Resource in a hypermedia document.
+type documentEncoder interface {
+	framework.Encoder
+	document(c *Context, re *Resource, entity bool, data interface{}) (interface{}, error)
+}
+
+// HALEncoder encodes the data rendered by Resources as HAL, adding _links to
+// the entities and embedding the items of collections. Other data is encoded
+// as JSON.
+type HALEncoder struct{}
+
+func (HALEncoder) MimeType() string {
+	return MIME_HAL_JSON
+}
+
+func (HALEncoder) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (HALEncoder) document(c *Context, re *Resource, entity bool, data interface{}) (interface{}, error) {
+	v, err := toDocumentValue(data)
+	if err != nil {
+		return nil, err
+	}
+	l := newResourceLinks(c, re)
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if id, ok := l.id(t, entity); ok {
+			halLinks(t, l, id, true)
+		}
+		return t, nil
+	case []interface{}:
+		for _, item := range t {
+			if m, ok := item.(map[string]interface{}); ok {
+				if id, ok := documentID(m); ok {
+					halLinks(m, l, id, false)
+				}
+			}
+		}
+		links := map[string]interface{}{
+			"self": halLink(c.URL.RequestURI()),
+		}
+		for rel, href := range pageLinks(c, data) {
+			links[rel] = halLink(href)
+		}
+		return map[string]interface{}{
+			"_links":    links,
+			"_embedded": map[string]interface{}{re.Name: t},
+		}, nil
+	}
+	return v, nil
+}
+
+func halLink(href string) map[string]interface{} {
+	return map[string]interface{}{"href": href}
+}
+
+func halLinks(m map[string]interface{}, l *resourceLinks, id string, collection bool) {
+	links := map[string]interface{}{
+		"self": halLink(l.entity(id)),
+	}
+	if collection {
+		links["collection"] = halLink(l.collection())
+	}
+	for _, child := range l.re.children {
+		links[child.Name] = halLink(l.child(id, child))
+	}
+	m["_links"] = links
+}
+
+// resourceLinks builds the links of the entities of a Resource with its
+// routes, filling their params with those of the request.
+type resourceLinks struct {
+	re     *Resource
+	values map[string]string
+}
+
+func newResourceLinks(c *Context, re *Resource) *resourceLinks {
+	return &resourceLinks{
+		re:     re,
+		values: c.pathValues,
+	}
+}
+
+// id returns the id of an entity, or the one in the path of the request if
+// the entity is the one requested.
+func (l *resourceLinks) id(m map[string]interface{}, entity bool) (string, bool) {
+	if id, ok := documentID(m); ok {
+		return id, true
+	}
+	if entity {
+		id, ok := l.values[l.re.paramName]
+		return id, ok
+	}
+	return "", false
+}
+
+func (l *resourceLinks) params(id string) map[string]string {
+	values := make(map[string]string, len(l.values)+1)
+	for k, v := range l.values {
+		values[k] = v
+	}
+	values[l.re.paramName] = id
+	return values
+}
+
+func (l *resourceLinks) collection() string {
+	return l.re.Route.BuildPath(l.values)
+}
+
+func (l *resourceLinks) entity(id string) string {
+	return l.re.EntityRoute.BuildPath(l.params(id))
+}
+
+func (l *resourceLinks) child(id string, child *Resource) string {
+	return child.Route.BuildPath(l.params(id))
+}
+
+// JSONAPIEncoder encodes the data rendered by Resources as JSON:API
+// documents. Fields holding objects with an id, or lists of them, become
+// relationships, which can be included with the include query param.
+// Sparse fieldsets are selected with the fields[type] query params. Other
+// data is encoded as JSON.
+type JSONAPIEncoder struct{}
+
+func (JSONAPIEncoder) MimeType() string {
+	return MIME_JSON_API
+}
+
+func (JSONAPIEncoder) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+type jsonAPIDocument struct {
+	fields   map[string]map[string]bool
+	include  map[string]bool
+	included []interface{}
+	seen     map[string]bool
+}
+
+func (JSONAPIEncoder) document(c *Context, re *Resource, entity bool, data interface{}) (interface{}, error) {
+	v, err := toDocumentValue(data)
+	if err != nil {
+		return nil, err
+	}
+	q := c.URL.Query()
+	d := &jsonAPIDocument{
+		fields:  parseFieldsets(q),
+		include: map[string]bool{},
+		seen:    map[string]bool{},
+	}
+	for _, name := range strings.Split(q.Get("include"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			d.include[strings.Split(name, ".")[0]] = true
+		}
+	}
+	l := newResourceLinks(c, re)
+
+	doc := map[string]interface{}{
+		"jsonapi": map[string]interface{}{"version": "1.0"},
+	}
+	links := map[string]interface{}{
+		"self": c.URL.RequestURI(),
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		self := ""
+		if id, ok := l.id(t, entity); ok {
+			self = l.entity(id)
+		}
+		doc["data"] = d.resourceObject(re.Name, t, self, true)
+	case []interface{}:
+		items := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				items = append(items, item)
+				continue
+			}
+			self := ""
+			if id, ok := documentID(m); ok {
+				self = l.entity(id)
+			}
+			items = append(items, d.resourceObject(re.Name, m, self, true))
+		}
+		doc["data"] = items
+		for rel, href := range pageLinks(c, data) {
+			links[rel] = href
+		}
+	default:
+		doc["data"] = t
+	}
+
+	doc["links"] = links
+	if len(d.include) > 0 {
+		if d.included == nil {
+			d.included = []interface{}{}
+		}
+		doc["included"] = d.included
+	}
+	return doc, nil
+}
+
+// resourceObject converts an entity to a JSON:API resource object, adding
+// the related entities to the included ones if requested.
+func (d *jsonAPIDocument) resourceObject(typ string, m map[string]interface{}, self string, primary bool) map[string]interface{} {
+	id, _ := documentID(m)
+	obj := map[string]interface{}{
+		"type": typ,
+		"id":   id,
+	}
+	fields, sparse := d.fields[typ]
+
+	attributes := map[string]interface{}{}
+	relationships := map[string]interface{}{}
+	for k, v := range m {
+		if k == "id" || (sparse && !fields[k]) {
+			continue
+		}
+		related, linkage, ok := relationship(k, v)
+		if !ok {
+			attributes[k] = v
+			continue
+		}
+		relationships[k] = map[string]interface{}{"data": linkage}
+		if primary && d.include[k] {
+			for _, r := range related {
+				d.includeObject(inflector.Pluralize(k), r)
+			}
+		}
+	}
+
+	if len(attributes) > 0 {
+		obj["attributes"] = attributes
+	}
+	if len(relationships) > 0 {
+		obj["relationships"] = relationships
+	}
+	if self != "" {
+		obj["links"] = map[string]interface{}{"self": self}
+	}
+	return obj
+}
+
+func (d *jsonAPIDocument) includeObject(typ string, m map[string]interface{}) {
+	id, _ := documentID(m)
+	key := typ + "/" + id
+	if d.seen[key] {
+		return
+	}
+	d.seen[key] = true
+	d.included = append(d.included, d.resourceObject(typ, m, "", false))
+}
+
+// relationship returns the related entities and the resource linkage of a
+// field holding an object with an id or a non-empty list of them.
+func relationship(name string, v interface{}) ([]map[string]interface{}, interface{}, bool) {
+	typ := inflector.Pluralize(name)
+	identifier := func(m map[string]interface{}) (map[string]interface{}, bool) {
+		id, ok := documentID(m)
+		if !ok {
+			return nil, false
+		}
+		return map[string]interface{}{"type": typ, "id": id}, true
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		ri, ok := identifier(t)
+		if !ok {
+			return nil, nil, false
+		}
+		return []map[string]interface{}{t}, ri, true
+	case []interface{}:
+		if len(t) == 0 {
+			return nil, nil, false
+		}
+		related := make([]map[string]interface{}, 0, len(t))
+		linkage := make([]interface{}, 0, len(t))
+		for _, item := range t {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, nil, false
+			}
+			ri, ok := identifier(m)
+			if !ok {
+				return nil, nil, false
+			}
+			related = append(related, m)
+			linkage = append(linkage, ri)
+		}
+		return related, linkage, true
+	}
+	return nil, nil, false
+}
+
+// parseFieldsets returns the fields selected by type with the fields[type]
+// query params.
+func parseFieldsets(q url.Values) map[string]map[string]bool {
+	fieldsets := map[string]map[string]bool{}
+	for k, vs := range q {
+		if !strings.HasPrefix(k, "fields[") || !strings.HasSuffix(k, "]") {
+			continue
+		}
+		typ := k[len("fields[") : len(k)-1]
+		fields := map[string]bool{}
+		for _, v := range vs {
+			for _, f := range strings.Split(v, ",") {
+				if f = strings.TrimSpace(f); f != "" {
+					fields[f] = true
+				}
+			}
+		}
+		fieldsets[typ] = fields
+	}
+	return fieldsets
+}
+
+// toDocumentValue converts data to the maps and slices it is encoded as in
+// JSON.
+func toDocumentValue(data interface{}) (interface{}, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	err = dec.Decode(&v)
+	return v, err
+}
+
+func documentID(m map[string]interface{}) (string, bool) {
+	id, ok := m["id"]
+	if !ok || id == nil {
+		return "", false
+	}
+	return fmt.Sprint(id), true
+}
+
+// pageLinks returns the pagination links of the current page, by relation.
+func pageLinks(c *Context, result interface{}) map[string]string {
+	links := map[string]string{}
+	pg := c.Page()
+	if pg == nil || pg.Limit <= 0 {
+		return links
+	}
+	for _, l := range pg.linkList(c.operation.pagination, c.URL, result) {
+		links[l[0]] = l[1]
+	}
+	return links
+}
+
+// Returns adds media types to the operations of the Resource, such as
+// MIME_HAL_JSON or MIME_JSON_API.
+func (r *Resource) Returns(returns ...string) *Resource {
+	for _, o := range r.operations() {
+		o.Returns(returns...)
+	}
+	return r
+}
+
+func (r *Resource) operations() []*Operation {
+	var ops []*Operation
+	for _, o := range []*Operation{r.Index, r.Create, r.Get, r.Update, r.Patch, r.Delete} {
+		if o != nil {
+			ops = append(ops, o)
+		}
+	}
+	return ops
+}
@@ -0,0 +1,223 @@
+package mirango
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mirango/framework"
+	"github.com/mirango/validation"
+)
+
+// Message codes of the errors reported by Mirango.
+const (
+	MSG_REQUIRED          = "required"
+	MSG_INVALID_SIGNATURE = "invalid_signature"
+	MSG_ENUM              = "enum"
+	MSG_NUMBER            = "number"
+	MSG_MIN               = "min"
+	MSG_MAX               = "max"
+	MSG_MIN_LENGTH        = "min_length"
+	MSG_MAX_LENGTH        = "max_length"
+	MSG_PATTERN           = "pattern"
+	MSG_FORMAT            = "format"
+	MSG_TYPE              = "type"
+	MSG_INVALID_PARAMS    = "invalid_params"
+	MSG_FIELD             = "field"
+	MSG_DEPRECATED        = "deprecated"
+	MSG_DEPRECATED_ALIAS  = "deprecated_alias"
+)
+
+// DefaultLanguage is the language used when no translation matches the
+// request.
+const DefaultLanguage = "en"
+
+// DefaultMessages holds the English messages. Messages are fmt formats, use
+// explicit argument indexes such as %[2]v to reorder the arguments.
+var DefaultMessages = map[string]string{
+	MSG_REQUIRED:          "parameter is required",
+	MSG_INVALID_SIGNATURE: "invalid cookie signature",
+	MSG_ENUM:              "value must be one of: %v",
+	MSG_NUMBER:            "value must be a number",
+	MSG_MIN:               "value must be greater than or equal to %v",
+	MSG_MAX:               "value must be less than or equal to %v",
+	MSG_MIN_LENGTH:        "value must be at least %d characters long",
+	MSG_MAX_LENGTH:        "value must be at most %d characters long",
+	MSG_PATTERN:           "value must match %v",
+	MSG_FORMAT:            "value must be a valid %v",
+	MSG_TYPE:              "value must be a valid %v",
+	MSG_INVALID_PARAMS:    "the request has invalid parameters",
+	MSG_FIELD:             "field %v cannot be selected",
+	MSG_DEPRECATED:        "parameter %v is deprecated",
+	MSG_DEPRECATED_ALIAS:  "parameter %v is deprecated, use %v instead",
+}
+
+// Catalog holds the messages of each language, keyed by message code.
+type Catalog struct {
+	languages map[string]map[string]string
+}
+
+func NewCatalog() *Catalog {
+	c := &Catalog{
+		languages: map[string]map[string]string{},
+	}
+	c.Register(DefaultLanguage, DefaultMessages)
+	return c
+}
+
+// Register adds the messages of a language, replacing the existing ones
+// with the same codes.
+func (c *Catalog) Register(lang string, messages map[string]string) {
+	lang = strings.ToLower(lang)
+	msgs, ok := c.languages[lang]
+	if !ok {
+		msgs = map[string]string{}
+		c.languages[lang] = msgs
+	}
+	for code, msg := range messages {
+		msgs[code] = msg
+	}
+}
+
+// Languages returns the registered languages.
+func (c *Catalog) Languages() []string {
+	var langs []string
+	for lang := range c.languages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Message returns the message with the given code in the given language,
+// falling back to the base language ("pt" for "pt-br") then to
+// DefaultLanguage.
+func (c *Catalog) Message(lang string, code string, args ...interface{}) string {
+	msg, ok := c.lookup(strings.ToLower(lang), code)
+	if !ok {
+		msg, ok = c.lookup(DefaultLanguage, code)
+		if !ok {
+			msg = code
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+func (c *Catalog) lookup(lang string, code string) (string, bool) {
+	for lang != "" {
+		if msgs, ok := c.languages[lang]; ok {
+			if msg, ok := msgs[code]; ok {
+				return msg, true
+			}
+		}
+		i := strings.LastIndex(lang, "-")
+		if i == -1 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return "", false
+}
+
+// has reports whether lang, or its base language, is registered.
+func (c *Catalog) has(lang string) (string, bool) {
+	for lang != "" {
+		if _, ok := c.languages[lang]; ok {
+			return lang, true
+		}
+		i := strings.LastIndex(lang, "-")
+		if i == -1 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return "", false
+}
+
+// negotiate returns the registered language preferred by the given
+// Accept-Language header.
+func (c *Catalog) negotiate(header string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				v, err := strconv.ParseFloat(f[2:], 64)
+				if err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	for _, t := range tags {
+		if t.lang == "*" {
+			break
+		}
+		if lang, ok := c.has(t.lang); ok {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
+
+var defaultCatalog = NewCatalog()
+
+// Message returns the message with the given code in the language of the
+// request.
+func (c *Context) Message(code string, args ...interface{}) string {
+	catalog := c.catalog
+	if catalog == nil {
+		catalog = defaultCatalog
+	}
+	return catalog.Message(c.language, code, args...)
+}
+
+// Language returns the language negotiated from the Accept-Language header.
+func (c *Context) Language() string {
+	if c.language == "" {
+		return DefaultLanguage
+	}
+	return c.language
+}
+
+func (c *Context) setLanguage(catalog *Catalog) {
+	c.catalog = catalog
+	c.language = catalog.negotiate(c.Request.Header.Get("Accept-Language"))
+	c.locale = locale(c.language)
+}
+
+// locale is the framework.Locale set on Context from the negotiated language.
+type locale string
+
+func (l locale) String() string {
+	return string(l)
+}
+
+// newError returns a validation error with the message of the given code in
+// the language of the request.
+func newError(c framework.Context, code string, args ...interface{}) error {
+	if mc, ok := c.(*Context); ok {
+		return validation.NewError(mc.Message(code, args...))
+	}
+	return validation.NewError(defaultCatalog.Message(DefaultLanguage, code, args...))
+}
@@ -1,7 +1,7 @@
 package mirango
 
 import (
-	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/mirango/defaults"
@@ -10,6 +10,10 @@ import (
 	"github.com/mirango/validation"
 )
 
+// operationErrorKey is the key under which the errors of operation-level
+// validators are reported.
+const operationErrorKey = "_"
+
 type Middleware interface {
 	Run(Handler) Handler
 }
@@ -46,35 +50,13 @@ func CheckSchemes(o *Operation) Middleware {
 }
 
 func getEncodingFromAccept(returns []string, r *Request) (string, *errors.Error) {
-	var encoding string
-
-	parts := strings.Split(r.Header.Get(framework.HEADER_Accept), ",")
-
-	for _, acceptMime := range parts {
-		mime := strings.Trim(strings.Split(acceptMime, ";")[0], " ")
-		if 0 == len(mime) || mime == "*/*" {
-			if len(returns) == 0 {
-				encoding = defaults.MimeType
-				break
-			} else {
-				encoding = returns[0]
-				break
-			}
-		} else {
-			if containsString(returns, mime) {
-				encoding = mime
-				break
-			}
-		}
+	if len(returns) == 0 {
+		returns = []string{defaults.MimeType}
 	}
 
-	if len(parts) == 0 {
-		encoding = defaults.MimeType
-	}
-
-	if len(encoding) == 0 {
-		encoding = defaults.MimeType
-		return encoding, errors.New(406, "Encoding requested not valid.")
+	encoding, ok := NegotiateContentType(r.Request, returns)
+	if !ok {
+		return defaults.MimeType, errors.New(406, "Encoding requested not valid.")
 	}
 
 	return encoding, nil
@@ -84,6 +66,7 @@ func CheckReturns(o *Operation) Middleware {
 	return MiddlewareFunc(func(next Handler) Handler {
 		return HandlerFunc(func(c *Context) interface{} {
 			// if mimeInAccept {
+			addVary(c.Response.Header(), framework.HEADER_Accept)
 			enc, err := getEncodingFromAccept(o.returns, c.Request)
 			c.encoding = enc
 			if err != nil {
@@ -101,11 +84,22 @@ func CheckReturns(o *Operation) Middleware {
 func CheckAccepts(o *Operation) Middleware {
 	return MiddlewareFunc(func(next Handler) Handler {
 		return HandlerFunc(func(c *Context) interface{} {
-			// enc, err := getEncodingFromAccept(accepts, c.Request)
-			// if err != nil {
-			// 	return err
-			// }
-			// c.encoding = enc
+			if len(o.accepts) == 0 || !hasBody(c.Request.Request) {
+				return next.ServeHTTP(c)
+			}
+
+			enc, ok := getEncodingFromContentType(o.accepts, c.Request)
+			if !ok {
+				h := c.Response.Header()
+				switch c.Request.Request.Method {
+				case "POST":
+					h.Set("Accept-Post", strings.Join(o.accepts, ", "))
+				case "PATCH":
+					h.Set("Accept-Patch", strings.Join(o.accepts, ", "))
+				}
+				return errors.New(http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType))
+			}
+			c.requestEncoding = enc
 
 			return next.ServeHTTP(c)
 		})
@@ -127,16 +121,51 @@ func CheckParams(o *Operation) Middleware {
 			if params.containsBodyParams {
 				c.ParseForm()
 			}
+			mode := o.GetValidationMode()
+			// params that already have an error are not validated
+			failed := map[string]bool{}
+			fail := func(name string, err error) {
+				if errs == nil {
+					errs = &validation.Error{}
+				}
+				errs.Append(name, err)
+				failed[name] = true
+			}
+
 			for _, p := range params.GetAll() {
 				var pv *validation.Value
-				if p.IsIn(IN_QUERY) {
-					v, ok := q[p.name]
+				var raw []string
+				if p.IsIn(IN_QUERY) && p.GetStyle() == STYLE_DEEP_OBJECT {
+					var obj interface{}
+					var members []string
+					var used string
+					ok := false
+					for _, name := range p.names() {
+						obj, members, ok = parseDeepObject(q, name)
+						if ok {
+							used = name
+							break
+						}
+					}
 					if !ok {
 						if p.IsRequired() {
-							if errs == nil {
-								errs = &validation.Error{}
-							}
-							errs.Append(p.name, validation.NewError("parameter is required"))
+							fail(p.name, newError(c, MSG_REQUIRED))
+							pv = validation.NewValue(p.name, "", "query", p.GetAs())
+						}
+					} else {
+						raw = members
+						c.objects[p.name] = obj
+						c.warnDeprecated(p, used)
+						pv = validation.NewMultipleValue(p.name, members, "query", p.GetAs())
+					}
+				} else if p.IsIn(IN_QUERY) {
+					v, used, ok := lookupParam(p, func(name string) ([]string, bool) {
+						v, ok := q[name]
+						return v, ok
+					})
+					if !ok {
+						if p.IsRequired() {
+							fail(p.name, newError(c, MSG_REQUIRED))
 							if !p.IsMultiple() {
 								pv = validation.NewValue(p.name, "", "query", p.GetAs())
 							} else {
@@ -144,6 +173,8 @@ func CheckParams(o *Operation) Middleware {
 							}
 						}
 					} else {
+						raw = v
+						c.warnDeprecated(p, used)
 						if !p.IsMultiple() {
 							pv = validation.NewValue(p.name, v[0], "query", p.GetAs())
 						} else {
@@ -151,13 +182,12 @@ func CheckParams(o *Operation) Middleware {
 						}
 					}
 				} else if p.IsIn(IN_HEADER) {
-					v, ok := h[p.name]
+					v, used, ok := lookupParam(p, func(name string) ([]string, bool) {
+						return getHeaderValues(h, name, p.IsMultiple())
+					})
 					if !ok {
 						if p.IsRequired() {
-							if errs == nil {
-								errs = &validation.Error{}
-							}
-							errs.Append(p.name, validation.NewError("parameter is required"))
+							fail(p.name, newError(c, MSG_REQUIRED))
 							if !p.IsMultiple() {
 								pv = validation.NewValue(p.name, "", "header", p.GetAs())
 							} else {
@@ -165,21 +195,42 @@ func CheckParams(o *Operation) Middleware {
 							}
 						}
 					} else {
+						raw = v
+						c.warnDeprecated(p, used)
 						if !p.IsMultiple() {
 							pv = validation.NewValue(p.name, v[0], "header", p.GetAs())
 						} else {
 							pv = validation.NewMultipleValue(p.name, v, "header", p.GetAs())
 						}
 					}
+				} else if p.IsIn(IN_COOKIE) {
+					v, used, verified := getCookieValues(c, p)
+					if !verified {
+						fail(p.name, newError(c, MSG_INVALID_SIGNATURE))
+					} else if len(v) == 0 {
+						if p.IsRequired() {
+							fail(p.name, newError(c, MSG_REQUIRED))
+							if !p.IsMultiple() {
+								pv = validation.NewValue(p.name, "", "cookie", p.GetAs())
+							} else {
+								pv = validation.NewMultipleValue(p.name, []string{""}, "cookie", p.GetAs())
+							}
+						}
+					} else {
+						raw = v
+						c.warnDeprecated(p, used)
+						if !p.IsMultiple() {
+							pv = validation.NewValue(p.name, v[0], "cookie", p.GetAs())
+						} else {
+							pv = validation.NewMultipleValue(p.name, v, "cookie", p.GetAs())
+						}
+					}
 				} else if p.IsIn(IN_BODY) { // decide what to do when content type is form-encoded
 					if p.IsFile() {
 						_, ok := c.MultipartForm.File[p.name]
 						if !ok {
 							if p.IsRequired() {
-								if errs == nil {
-									errs = &validation.Error{}
-								}
-								errs.Append(p.name, validation.NewError("parameter is required"))
+								fail(p.name, newError(c, MSG_REQUIRED))
 							}
 						} else {
 							//pv = NewFileParamValue(p.name, v[0], "header")
@@ -188,22 +239,19 @@ func CheckParams(o *Operation) Middleware {
 						_, ok := c.MultipartForm.Value[p.name]
 						if !ok {
 							if p.IsRequired() {
-								if errs == nil {
-									errs = &validation.Error{}
-								}
-								errs.Append(p.name, validation.NewError("parameter is required"))
+								fail(p.name, newError(c, MSG_REQUIRED))
 							}
 						} else {
 							//pv = NewFileParamValue(p.name, v[0], "header")
 						}
 					} else {
-						v, ok := c.Form[p.name]
+						v, used, ok := lookupParam(p, func(name string) ([]string, bool) {
+							v, ok := c.Form[name]
+							return v, ok
+						})
 						if !ok {
 							if p.IsRequired() {
-								if errs == nil {
-									errs = &validation.Error{}
-								}
-								errs.Append(p.name, fmt.Errorf("param %s is required", p.name))
+								fail(p.name, newError(c, MSG_REQUIRED))
 								if !p.IsMultiple() {
 									pv = validation.NewValue(p.name, "", "body", p.GetAs())
 								} else {
@@ -211,6 +259,8 @@ func CheckParams(o *Operation) Middleware {
 								}
 							}
 						} else {
+							raw = v
+							c.warnDeprecated(p, used)
 							if !p.IsMultiple() {
 								pv = validation.NewValue(p.name, v[0], "body", p.GetAs())
 							} else {
@@ -219,12 +269,37 @@ func CheckParams(o *Operation) Middleware {
 						}
 					}
 				}
+				if p.IsIn(IN_PATH) {
+					if v, ok := c.pathValues[p.name]; ok {
+						raw = []string{v}
+					}
+				}
+				if p.typeName != "" && len(raw) > 0 && c.types != nil {
+					t := c.types.Get(p.typeName)
+					if t != nil {
+						var parsed interface{}
+						var err error
+						if p.GetStyle() == STYLE_DEEP_OBJECT {
+							parsed, err = parseDeepValues(t, c.objects[p.name])
+						} else {
+							parsed, err = parseValues(t, p, raw)
+						}
+						if err != nil {
+							fail(p.name, newError(c, MSG_TYPE, p.typeName))
+						} else {
+							c.parsed[p.name] = parsed
+						}
+					}
+				}
 				if pv != nil {
 					c.input.Append(pv)
 				}
+				if errs != nil && mode == VALIDATE_ONE {
+					return errs
+				}
 			}
 
-			vErrs := params.ValidateAll(c, c.input)
+			vErrs := params.except(failed).ValidateWith(mode, c, c.input)
 			if vErrs != nil {
 				if errs == nil {
 					errs = &validation.Error{}
@@ -232,6 +307,26 @@ func CheckParams(o *Operation) Middleware {
 				errs.UnionAppend(*vErrs)
 			}
 
+			if errs == nil || mode != VALIDATE_ONE {
+				for _, f := range o.validators {
+					err := f(c, c.input)
+					if err == nil {
+						continue
+					}
+					if errs == nil {
+						errs = &validation.Error{}
+					}
+					if vErr, ok := err.(*validation.Error); ok {
+						errs.UnionAppend(*vErr)
+					} else {
+						errs.Append(operationErrorKey, err)
+					}
+					if mode == VALIDATE_ONE {
+						break
+					}
+				}
+			}
+
 			if errs != nil {
 				return errs
 			}
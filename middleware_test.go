@@ -0,0 +1,79 @@
+package mirango
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mirango/framework"
+)
+
+type countingValidator struct {
+	calls int
+	err   error
+}
+
+func (v *countingValidator) Validate(c framework.Context, pv framework.ParamValue) error {
+	v.calls++
+	return v.err
+}
+
+func TestCheckParamsModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     validationMode
+		query    string
+		invalidB bool
+		valid    bool
+		aCalls   int
+		bCalls   int
+		opCalls  int
+	}{
+		{"valid", VALIDATE_ALL, "a=1&b=1", false, true, 1, 1, 1},
+		{"all: missing params are not validated", VALIDATE_ALL, "b=1", false, false, 0, 1, 1},
+		{"all: invalid params", VALIDATE_ALL, "a=1&b=1", true, false, 1, 1, 1},
+		{"first: missing params are not validated", VALIDATE_FIRST, "b=1", false, false, 0, 1, 1},
+		{"one: stops at a missing param", VALIDATE_ONE, "b=1", false, false, 0, 0, 0},
+		{"one: stops at an invalid param", VALIDATE_ONE, "a=1&b=1", true, false, -1, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			va := &countingValidator{}
+			vb := &countingValidator{}
+			if tt.invalidB {
+				vb.err = errors.New("invalid")
+			}
+			opCalls := 0
+			o := NewOperation(func(c *Context) interface{} { return nil }).Params(
+				QueryParam("a").Required().Validators(va),
+				QueryParam("b").Required().Validators(vb),
+			).ValidationMode(tt.mode).Validate(func(c *Context, v framework.ParamValues) error {
+				opCalls++
+				return nil
+			})
+
+			r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+			c := NewContext(NewResponse(httptest.NewRecorder(), nil), NewRequest(r))
+			called := false
+			res := CheckParams(o).Run(HandlerFunc(func(c *Context) interface{} {
+				called = true
+				return nil
+			})).ServeHTTP(c)
+
+			if called != tt.valid {
+				t.Errorf("handler called: %v, result %v", called, res)
+			}
+			// a negative count means any, as params are validated in no
+			// particular order
+			if tt.aCalls >= 0 && va.calls != tt.aCalls {
+				t.Errorf("%d validator calls for a, want %d", va.calls, tt.aCalls)
+			}
+			if vb.calls != tt.bCalls {
+				t.Errorf("%d validator calls for b, want %d", vb.calls, tt.bCalls)
+			}
+			if opCalls != tt.opCalls {
+				t.Errorf("%d operation validator calls, want %d", opCalls, tt.opCalls)
+			}
+		})
+	}
+}
@@ -21,8 +21,10 @@
 package mirango
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/mirango/errors"
 	"github.com/mirango/framework"
 )
 
@@ -33,13 +35,21 @@ type Mirango struct {
 	encoders      framework.Encoders
 	decoders      framework.Decoders
 	logger        framework.Logger
+	catalog       *Catalog
+	types         *ValueTypes
+	compression   *Compression
+	etags         etagMode
+	templates     *Templates
+	operations    map[string]*Operation
 	sessionStores []framework.SessionStore // add ability to make sessions on different stores
 }
 
 func New(path interface{}) *Mirango {
 	r := NewRoute(path)
 	m := &Mirango{
-		Route: r,
+		Route:   r,
+		catalog: NewCatalog(),
+		types:   NewValueTypes(),
 	}
 	m.node = r.node.getRoot()
 	r.mirango = m
@@ -67,6 +77,31 @@ func (m *Mirango) Logger(l framework.Logger) {
 	m.logger = l
 }
 
+// Translations registers the messages of a language, keyed by message code.
+func (m *Mirango) Translations(lang string, messages map[string]string) {
+	m.catalog.Register(lang, messages)
+}
+
+func (m *Mirango) Catalog() *Catalog {
+	return m.catalog
+}
+
+// Compression enables the compression of responses with gzip or deflate,
+// negotiated from the Accept-Encoding header. A nil Compression uses
+// DefaultCompression. Partial responses are not compressed, and the ETags of
+// compressed responses are made weak.
+func (m *Mirango) Compression(cp *Compression) {
+	if cp == nil {
+		cp = DefaultCompression()
+	}
+	m.compression = cp
+}
+
+// ValueTypes registers value types that params can be declared as.
+func (m *Mirango) ValueTypes(types ...*ValueType) {
+	m.types.Append(types...)
+}
+
 func (m *Mirango) SessionStore(ss framework.SessionStore) {
 	if ss != nil {
 		m.sessionStores = append(m.sessionStores, ss)
@@ -85,6 +120,22 @@ func (m *Mirango) Server(s framework.Server) {
 func (m *Mirango) Prepare() {
 	m.route.finalize()
 	m.node.finalize()
+	m.operations = map[string]*Operation{}
+	m.Route.walk(func(r *Route) {
+		for _, o := range r.operations.GetAll() {
+			if o.name != "" {
+				m.operations[o.name] = o
+			}
+			if o.template != "" && m.templates == nil {
+				panic(fmt.Sprintf("Detected an operation with a template but no templates: \"%s\".", o.template))
+			}
+			for _, p := range o.params.GetAll() {
+				if p.typeName != "" && m.types.Get(p.typeName) == nil {
+					panic(fmt.Sprintf("Detected a param with an unknown value type: \"%s\".", p.typeName))
+				}
+			}
+		}
+	})
 }
 
 func (m *Mirango) Start(addr string) error {
@@ -112,9 +163,25 @@ func (m *Mirango) StartTLS(addr string, certFile string, keyFile string) error {
 }
 
 func (m *Mirango) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.compression != nil {
+		addVary(w.Header(), "Accept-Encoding")
+		if enc := negotiateCompression(r); enc != "" {
+			cw := newCompressWriter(w, enc, m.compression)
+			defer cw.Close()
+			w = cw
+		}
+	}
+
 	nr := NewRequest(r)
 	nw := NewResponse(w, m.encoders)
+	nw.ctx = r.Context()
+	nw.request = r
+	nw.etags = m.etags
 	c := NewContext(nw, nr)
+	c.setLanguage(m.catalog)
+	c.types = m.types
+	c.decoders = m.decoders
+	c.templates = m.templates
 
 	// defer log
 
@@ -140,6 +207,7 @@ func (m *Mirango) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	res, found, _ := m.node.match(r.URL.Path) // recommend redirection to standard path, tell if a match is found, otherwise return the latest found route
 	if found && res.node != nil && res.node.route != nil {
 		data := res.node.route.ServeHTTP(c, res)
+		nw.closeEvents()
 		// check data type
 		if !c.ended {
 			err := c.sessions.Save(r, nw)
@@ -164,6 +232,16 @@ func (m *Mirango) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					h.ServeHTTP(c)
 					return
 				}
+				pr := route.GetProblemRenderer()
+				if pr != nil {
+					c.route = route
+					err := nw.Render(c, errors.New(http.StatusNotFound, http.StatusText(http.StatusNotFound)))
+					if err != nil {
+						// log
+						c.WriteHeader(http.StatusInternalServerError)
+					}
+					return
+				}
 			}
 		}
 		c.WriteHeader(http.StatusNotFound)
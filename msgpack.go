@@ -0,0 +1,704 @@
+package mirango
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const MIME_MSGPACK = "application/msgpack"
+
+// MsgpackCodec encodes and decodes MessagePack. Struct fields are named
+// after their msgpack tag, or their json tag. time.Time values use the
+// timestamp extension type.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) MimeType() string {
+	return MIME_MSGPACK
+}
+
+func (MsgpackCodec) Encode(v interface{}) ([]byte, error) {
+	return marshalMsgpack(v)
+}
+
+func (MsgpackCodec) Decode(b []byte, v interface{}) error {
+	return unmarshalMsgpack(b, v)
+}
+
+const msgpackMaxDepth = 1000
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type msgpackEncoder struct {
+	buf bytes.Buffer
+}
+
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	e := &msgpackEncoder{}
+	err := e.encode(reflect.ValueOf(v), 0)
+	if err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+func (e *msgpackEncoder) encode(v reflect.Value, depth int) error {
+	if depth > msgpackMaxDepth {
+		return fmt.Errorf("msgpack: value too deep")
+	}
+	if !v.IsValid() {
+		e.buf.WriteByte(0xc0)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			return e.encode(v.Elem(), depth+1)
+		}
+	}
+
+	if v.Type() == timeType {
+		e.encodeTime(v.Interface().(time.Time))
+		return nil
+	}
+	if v.Type().Implements(textMarshalerType) && v.Kind() != reflect.Ptr {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return err
+		}
+		e.encodeString(string(b))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		return e.encode(v.Elem(), depth+1)
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.encodeUint(v.Uint())
+	case reflect.Float32:
+		e.buf.WriteByte(0xca)
+		e.writeUint(uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.buf.WriteByte(0xcb)
+		e.writeUint(math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.encodeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.encodeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			e.encodeBytes(b)
+			return nil
+		}
+		return e.encodeArray(v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			e.buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := v.MapKeys()
+		if v.Type().Key().Kind() == reflect.String {
+			sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		}
+		e.writeHeader(len(keys), 0x80, 16, 0xde, 0xdf)
+		for _, k := range keys {
+			err := e.encode(k, depth+1)
+			if err != nil {
+				return err
+			}
+			err = e.encode(v.MapIndex(k), depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		var fields []codecField
+		var values []reflect.Value
+		for _, f := range codecFields(v.Type(), "msgpack") {
+			fv := v.FieldByIndex(f.index)
+			if f.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			fields = append(fields, f)
+			values = append(values, fv)
+		}
+		e.writeHeader(len(fields), 0x80, 16, 0xde, 0xdf)
+		for i, f := range fields {
+			e.encodeString(f.name)
+			err := e.encode(values[i], depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %s", v.Type())
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeArray(v reflect.Value, depth int) error {
+	e.writeHeader(v.Len(), 0x90, 16, 0xdc, 0xdd)
+	for i := 0; i < v.Len(); i++ {
+		err := e.encode(v.Index(i), depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeHeader writes the header of a map or array, in its fix format if n
+// is under fixMax.
+func (e *msgpackEncoder) writeHeader(n int, fix byte, fixMax int, b16 byte, b32 byte) {
+	switch {
+	case n < fixMax:
+		e.buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(b16)
+		e.writeUint(uint64(n), 2)
+	default:
+		e.buf.WriteByte(b32)
+		e.writeUint(uint64(n), 4)
+	}
+}
+
+func (e *msgpackEncoder) writeUint(u uint64, size int) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], u)
+	e.buf.Write(b[8-size:])
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case i >= 0:
+		e.encodeUint(uint64(i))
+	case i >= -32:
+		e.buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		e.buf.WriteByte(0xd0)
+		e.writeUint(uint64(i), 1)
+	case i >= math.MinInt16:
+		e.buf.WriteByte(0xd1)
+		e.writeUint(uint64(i), 2)
+	case i >= math.MinInt32:
+		e.buf.WriteByte(0xd2)
+		e.writeUint(uint64(i), 4)
+	default:
+		e.buf.WriteByte(0xd3)
+		e.writeUint(uint64(i), 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeUint(u uint64) {
+	switch {
+	case u < 128:
+		e.buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		e.buf.WriteByte(0xcc)
+		e.writeUint(u, 1)
+	case u <= math.MaxUint16:
+		e.buf.WriteByte(0xcd)
+		e.writeUint(u, 2)
+	case u <= math.MaxUint32:
+		e.buf.WriteByte(0xce)
+		e.writeUint(u, 4)
+	default:
+		e.buf.WriteByte(0xcf)
+		e.writeUint(u, 8)
+	}
+}
+
+func (e *msgpackEncoder) encodeString(s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		e.buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xd9)
+		e.writeUint(uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xda)
+		e.writeUint(uint64(n), 2)
+	default:
+		e.buf.WriteByte(0xdb)
+		e.writeUint(uint64(n), 4)
+	}
+	e.buf.WriteString(s)
+}
+
+func (e *msgpackEncoder) encodeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		e.buf.WriteByte(0xc4)
+		e.writeUint(uint64(n), 1)
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(0xc5)
+		e.writeUint(uint64(n), 2)
+	default:
+		e.buf.WriteByte(0xc6)
+		e.writeUint(uint64(n), 4)
+	}
+	e.buf.Write(b)
+}
+
+// encodeTime writes t with the timestamp extension type, in its 96-bit
+// format.
+func (e *msgpackEncoder) encodeTime(t time.Time) {
+	e.buf.Write([]byte{0xc7, 12, 0xff})
+	e.writeUint(uint64(t.Nanosecond()), 4)
+	e.writeUint(uint64(t.Unix()), 8)
+}
+
+type msgpackDecoder struct {
+	b   []byte
+	pos int
+}
+
+func unmarshalMsgpack(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: cannot decode into non-pointer value (%T)", v)
+	}
+	d := &msgpackDecoder{b: b}
+	src, err := d.decode(0)
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.b) {
+		return fmt.Errorf("msgpack: unexpected data after the top-level value")
+	}
+	return assignMsgpack(src, rv.Elem())
+}
+
+func (d *msgpackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.pos < n {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	b := d.b[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// decode reads a value as nil, bool, int64, uint64, float64, string, []byte,
+// time.Time, []interface{}, map[string]interface{} or, if its keys are not
+// all strings, map[interface{}]interface{}.
+func (d *msgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > msgpackMaxDepth {
+		return nil, fmt.Errorf("msgpack: value too deep")
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.decodeMap(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.decodeArray(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		s, err := d.read(int(c & 0x1f))
+		return string(s), err
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.read(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), s...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readUint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	case 0xca:
+		u, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.readUint(1 << (c - 0xcc))
+	case 0xd0:
+		u, err := d.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.readUint(8)
+		return int64(u), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.read(int(n))
+		return string(s), err
+	case 0xdc, 0xdd:
+		n, err := d.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n), depth)
+	}
+	return nil, fmt.Errorf("msgpack: invalid format 0x%x", c)
+}
+
+func (d *msgpackDecoder) decodeArray(n int, depth int) (interface{}, error) {
+	if n > len(d.b)-d.pos {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	arr := make([]interface{}, n)
+	for i := range arr {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int, depth int) (interface{}, error) {
+	if n > (len(d.b)-d.pos)/2 {
+		return nil, fmt.Errorf("msgpack: unexpected end of data")
+	}
+	keys := make([]interface{}, n)
+	values := make([]interface{}, n)
+	stringKeys := true
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.(string); !ok {
+			stringKeys = false
+		}
+		keys[i] = k
+		values[i] = v
+	}
+
+	if stringKeys {
+		m := make(map[string]interface{}, n)
+		for i, k := range keys {
+			m[k.(string)] = values[i]
+		}
+		return m, nil
+	}
+	m := make(map[interface{}]interface{}, n)
+	for i, k := range keys {
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack: invalid map key type %T", k)
+		}
+		m[k] = values[i]
+	}
+	return m, nil
+}
+
+// decodeExt reads an extension value. Only timestamps are supported.
+func (d *msgpackDecoder) decodeExt(n int) (interface{}, error) {
+	tb, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(tb[0]) != -1 {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d", int8(tb[0]))
+	}
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&0x3ffffffff), int64(u>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		sec := binary.BigEndian.Uint64(data[4:])
+		return time.Unix(int64(sec), int64(nsec)), nil
+	}
+	return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+// assignMsgpack assigns a decoded value to dst, converting it to the type
+// of dst.
+func assignMsgpack(src interface{}, dst reflect.Value) error {
+	if src == nil {
+		switch dst.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignMsgpack(src, dst.Elem())
+	}
+	if dst.Type() == timeType {
+		t, ok := src.(time.Time)
+		if !ok {
+			return msgpackTypeError(src, dst)
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if dst.CanAddr() {
+		if u, ok := dst.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			switch t := src.(type) {
+			case string:
+				return u.UnmarshalText([]byte(t))
+			case []byte:
+				return u.UnmarshalText(t)
+			}
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return msgpackTypeError(src, dst)
+		}
+		dst.Set(reflect.ValueOf(src))
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return msgpackTypeError(src, dst)
+		}
+		dst.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch t := src.(type) {
+		case int64:
+			i = t
+		case uint64:
+			if t > math.MaxInt64 {
+				return msgpackTypeError(src, dst)
+			}
+			i = int64(t)
+		default:
+			return msgpackTypeError(src, dst)
+		}
+		if dst.OverflowInt(i) {
+			return msgpackTypeError(src, dst)
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch t := src.(type) {
+		case uint64:
+			u = t
+		case int64:
+			if t < 0 {
+				return msgpackTypeError(src, dst)
+			}
+			u = uint64(t)
+		default:
+			return msgpackTypeError(src, dst)
+		}
+		if dst.OverflowUint(u) {
+			return msgpackTypeError(src, dst)
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		switch t := src.(type) {
+		case float64:
+			dst.SetFloat(t)
+		case int64:
+			dst.SetFloat(float64(t))
+		case uint64:
+			dst.SetFloat(float64(t))
+		default:
+			return msgpackTypeError(src, dst)
+		}
+	case reflect.String:
+		switch t := src.(type) {
+		case string:
+			dst.SetString(t)
+		case []byte:
+			dst.SetString(string(t))
+		default:
+			return msgpackTypeError(src, dst)
+		}
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch t := src.(type) {
+			case []byte:
+				dst.SetBytes(t)
+				return nil
+			case string:
+				dst.SetBytes([]byte(t))
+				return nil
+			}
+		}
+		arr, ok := src.([]interface{})
+		if !ok {
+			return msgpackTypeError(src, dst)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(arr), len(arr))
+		for i, v := range arr {
+			err := assignMsgpack(v, s.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+	case reflect.Array:
+		if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			if len(b) != dst.Len() {
+				return msgpackTypeError(src, dst)
+			}
+			reflect.Copy(dst, reflect.ValueOf(b))
+			return nil
+		}
+		arr, ok := src.([]interface{})
+		if !ok || len(arr) != dst.Len() {
+			return msgpackTypeError(src, dst)
+		}
+		for i, v := range arr {
+			err := assignMsgpack(v, dst.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(dst.Type()))
+		}
+		set := func(k interface{}, v interface{}) error {
+			kv := reflect.New(dst.Type().Key()).Elem()
+			err := assignMsgpack(k, kv)
+			if err != nil {
+				return err
+			}
+			ev := reflect.New(dst.Type().Elem()).Elem()
+			err = assignMsgpack(v, ev)
+			if err != nil {
+				return err
+			}
+			dst.SetMapIndex(kv, ev)
+			return nil
+		}
+		switch t := src.(type) {
+		case map[string]interface{}:
+			for k, v := range t {
+				if err := set(k, v); err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for k, v := range t {
+				if err := set(k, v); err != nil {
+					return err
+				}
+			}
+		default:
+			return msgpackTypeError(src, dst)
+		}
+	case reflect.Struct:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return msgpackTypeError(src, dst)
+		}
+		fields := codecFields(dst.Type(), "msgpack")
+		for k, v := range m {
+			f, ok := findCodecField(fields, k)
+			if !ok {
+				continue
+			}
+			err := assignMsgpack(v, dst.FieldByIndex(f.index))
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return msgpackTypeError(src, dst)
+	}
+	return nil
+}
+
+func msgpackTypeError(src interface{}, dst reflect.Value) error {
+	return fmt.Errorf("msgpack: cannot decode %T into %s", src, dst.Type())
+}
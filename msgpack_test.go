@@ -0,0 +1,168 @@
+package mirango
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackUser struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Email   string            `json:"email,omitempty"`
+	Tags    []string          `json:"tags"`
+	Scores  map[string]uint16 `json:"scores"`
+	Manager *msgpackUser      `json:"manager"`
+	Ignored string            `json:"-"`
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+	}{
+		{"nil", (*int)(nil)},
+		{"true", true},
+		{"false", false},
+		{"positive fixint", 127},
+		{"uint8", 255},
+		{"uint16", 65535},
+		{"uint32", uint32(math.MaxUint32)},
+		{"uint64", uint64(math.MaxUint64)},
+		{"negative fixint", -32},
+		{"int8", -128},
+		{"int16", -32768},
+		{"int32", int32(math.MinInt32)},
+		{"int64", int64(math.MinInt64)},
+		{"float32", float32(1.5)},
+		{"float64", math.Pi},
+		{"empty string", ""},
+		{"fixstr", strings.Repeat("a", 31)},
+		{"str8", strings.Repeat("a", 32)},
+		{"str16", strings.Repeat("a", 256)},
+		{"str32", strings.Repeat("a", 65536)},
+		{"unicode", "héllo, 世界"},
+		{"bytes", []byte{0, 1, 2, 255}},
+		{"array16", make([]int, 16)},
+		{"strings", []string{"a", "b"}},
+		{"map", map[string]int{"a": 1, "b": 2}},
+		{"int keys", map[int]string{1: "a", 2: "b"}},
+		{"struct", msgpackUser{
+			ID:     1,
+			Name:   "Ann",
+			Tags:   []string{"x"},
+			Scores: map[string]uint16{"go": 10},
+			Manager: &msgpackUser{
+				ID:   2,
+				Name: "Bob",
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := marshalMsgpack(tt.in)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			out := reflect.New(reflect.TypeOf(tt.in))
+			err = unmarshalMsgpack(b, out.Interface())
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(out.Elem().Interface(), tt.in) {
+				t.Errorf("got %#v, want %#v", out.Elem().Interface(), tt.in)
+			}
+		})
+	}
+}
+
+func TestMsgpackTime(t *testing.T) {
+	for _, in := range []time.Time{
+		time.Unix(1500000000, 0),
+		time.Unix(1500000000, 123456789),
+		time.Unix(1<<35, 1),
+		time.Unix(-1, 0),
+	} {
+		b, err := marshalMsgpack(in)
+		if err != nil {
+			t.Fatalf("marshal %v: %v", in, err)
+		}
+		var out time.Time
+		err = unmarshalMsgpack(b, &out)
+		if err != nil {
+			t.Fatalf("unmarshal %v: %v", in, err)
+		}
+		if !out.Equal(in) {
+			t.Errorf("got %v, want %v", out, in)
+		}
+	}
+}
+
+func TestMsgpackOmitEmpty(t *testing.T) {
+	b, err := marshalMsgpack(msgpackUser{ID: 1, Ignored: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	err = unmarshalMsgpack(b, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["email"]; ok {
+		t.Error("empty email is encoded")
+	}
+	if _, ok := m["Ignored"]; ok {
+		t.Error("ignored field is encoded")
+	}
+	if _, ok := m["id"]; !ok {
+		t.Error("id is not encoded")
+	}
+}
+
+func TestMsgpackMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		v    interface{}
+	}{
+		{"empty", nil, new(interface{})},
+		{"invalid format", []byte{0xc1}, new(interface{})},
+		{"truncated fixstr", []byte{0xa5, 'a'}, new(string)},
+		{"truncated str32 length", []byte{0xdb, 0xff, 0xff}, new(string)},
+		{"huge str32", []byte{0xdb, 0xff, 0xff, 0xff, 0xff, 'a'}, new(string)},
+		{"huge bin32", []byte{0xc6, 0xff, 0xff, 0xff, 0xff}, new([]byte)},
+		{"huge array32", []byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}, new([]int)},
+		{"huge map32", []byte{0xdf, 0xff, 0xff, 0xff, 0xff, 0xa1, 'a', 0x01}, new(map[string]int)},
+		{"truncated array", []byte{0x92, 0x01}, new([]int)},
+		{"truncated map", []byte{0x81, 0xa1, 'a'}, new(map[string]int)},
+		{"trailing data", []byte{0x01, 0x02}, new(int)},
+		{"unsupported extension", []byte{0xd4, 0x01, 0x00}, new(interface{})},
+		{"invalid timestamp length", []byte{0xc7, 0x03, 0xff, 0, 0, 0}, new(time.Time)},
+		{"unhashable map key", []byte{0x81, 0x90, 0x01}, new(interface{})},
+		{"too deep", append(bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2), 0x01), new(interface{})},
+		{"string into int", []byte{0xa1, 'a'}, new(int)},
+		{"overflowing int8", []byte{0xcd, 0x01, 0x00}, new(int8)},
+		{"negative into uint", []byte{0xff}, new(uint)},
+		{"non-pointer", []byte{0x01}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := unmarshalMsgpack(tt.in, tt.v); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
+
+func TestMsgpackTooDeepEncode(t *testing.T) {
+	var v interface{} = 1
+	for i := 0; i < msgpackMaxDepth+2; i++ {
+		v = []interface{}{v}
+	}
+	if _, err := marshalMsgpack(v); err == nil {
+		t.Error("no error")
+	}
+}
@@ -0,0 +1,195 @@
+package mirango
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a parsed element of an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	params  map[string]string
+	q       float64
+}
+
+func parseMediaType(s string) (string, string) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.IndexByte(s, '/')
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+func parseAccept(values []string) []mediaRange {
+	var ranges []mediaRange
+	for _, hv := range ParseHeaderValues(values) {
+		mr := mediaRange{
+			q: 1,
+		}
+		mr.typ, mr.subtype = parseMediaType(hv.Value)
+		if mr.subtype == "" {
+			if mr.typ != "*" {
+				continue
+			}
+			mr.subtype = "*"
+		}
+		for name, value := range hv.Params {
+			if name == "q" {
+				q, err := strconv.ParseFloat(value, 64)
+				if err == nil && q >= 0 && q <= 1 {
+					mr.q = q
+				}
+				continue
+			}
+			if mr.params == nil {
+				mr.params = map[string]string{}
+			}
+			mr.params[name] = value
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// match returns the specificity of the match of the range with the given
+// offer, or -1 if it does not match.
+func (mr mediaRange) match(offer HeaderValue) int {
+	typ, subtype := parseMediaType(offer.Value)
+	switch {
+	case mr.typ == "*" && mr.subtype == "*":
+		return 0
+	case mr.typ != typ:
+		return -1
+	case mr.subtype == "*":
+		return 1
+	case mr.subtype != subtype:
+		return -1
+	}
+	for name, value := range mr.params {
+		if v, ok := offer.Param(name); !ok || !strings.EqualFold(v, value) {
+			return -1
+		}
+	}
+	return 2 + len(mr.params)
+}
+
+// NegotiateContentType returns the offered media type preferred by the Accept
+// header of the request, as defined by RFC 7231. The ranges are ranked by
+// quality then specificity, ties are resolved by the order of the offers.
+// If the request has no Accept header the first offer is returned. It
+// returns false if no offer is acceptable.
+func NegotiateContentType(r *http.Request, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	values := r.Header.Values("Accept")
+	if len(values) == 0 {
+		return offers[0], true
+	}
+	ranges := parseAccept(values)
+	if len(ranges) == 0 {
+		return offers[0], true
+	}
+
+	best := ""
+	bestQ := 0.0
+	for _, offer := range offers {
+		o := ParseHeaderValues([]string{offer})
+		if len(o) == 0 {
+			continue
+		}
+		// the quality of an offer is the one of the most specific range
+		// matching it
+		q := 0.0
+		spec := -1
+		for _, mr := range ranges {
+			s := mr.match(o[0])
+			if s > spec {
+				spec = s
+				q = mr.q
+			}
+		}
+		if spec != -1 && q > bestQ {
+			best = offer
+			bestQ = q
+		}
+	}
+	return best, best != ""
+}
+
+// Negotiate returns the offered media type preferred by the request and
+// adds Accept to the Vary header of the response.
+func (c *Context) Negotiate(offers ...string) (string, bool) {
+	addVary(c.Response.Header(), "Accept")
+	return NegotiateContentType(c.Request.Request, offers)
+}
+
+// addVary adds the given header names to the Vary header, unless already
+// present.
+func addVary(h http.Header, names ...string) {
+	vary := splitHeaderValues(h.Values("Vary"))
+	for _, name := range names {
+		found := false
+		for _, v := range vary {
+			if v == "*" || strings.EqualFold(v, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Add("Vary", name)
+			vary = append(vary, name)
+		}
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength == -1 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// getEncodingFromContentType returns the entry of accepts matching the
+// Content-Type of the request. Entries may use wildcards, "application/json"
+// also matches "+json" types such as "application/vnd.api+json", and a
+// charset is only checked if the entry sets one. A request without a
+// Content-Type is considered "application/octet-stream".
+func getEncodingFromContentType(accepts []string, r *Request) (string, bool) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return "", false
+	}
+	typ, subtype := parseMediaType(mediaType)
+	suffix := ""
+	if i := strings.LastIndexByte(subtype, '+'); i != -1 {
+		suffix = subtype[i+1:]
+	}
+
+	for _, accept := range accepts {
+		am, aparams, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		atyp, asubtype := parseMediaType(am)
+		if atyp != "*" && atyp != typ {
+			continue
+		}
+		if asubtype != "*" && asubtype != subtype && asubtype != suffix {
+			continue
+		}
+		if charset, ok := aparams["charset"]; ok && !strings.EqualFold(charset, params["charset"]) {
+			continue
+		}
+		if atyp == "*" || asubtype == "*" {
+			return mediaType, true
+		}
+		return am, true
+	}
+	return "", false
+}
@@ -0,0 +1,53 @@
+package mirango
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	json := "application/json"
+	xml := "application/xml"
+	html := "text/html"
+	tests := []struct {
+		name   string
+		accept []string
+		offers []string
+		want   string
+		ok     bool
+	}{
+		{"no accept", nil, []string{json, xml}, json, true},
+		{"no offers", []string{json}, nil, "", false},
+		{"exact", []string{xml}, []string{json, xml}, xml, true},
+		{"case insensitive", []string{"Application/XML"}, []string{json, xml}, xml, true},
+		{"any", []string{"*/*"}, []string{xml, json}, xml, true},
+		{"subtype wildcard", []string{"text/*"}, []string{json, html}, html, true},
+		{"q-values", []string{"application/json;q=0.5, application/xml"}, []string{json, xml}, xml, true},
+		{"q-values across headers", []string{"application/json;q=0.5", "application/xml;q=0.8"}, []string{json, xml}, xml, true},
+		{"ties keep offer order", []string{"application/xml, application/json"}, []string{json, xml}, json, true},
+		{"specific range wins over wildcard", []string{"*/*;q=0.9, application/json;q=0.1"}, []string{json, xml}, xml, true},
+		{"q=0 excludes", []string{"application/json;q=0, */*"}, []string{json, xml}, xml, true},
+		{"q=0 excludes all", []string{"application/json;q=0"}, []string{json}, "", false},
+		{"not acceptable", []string{"image/png"}, []string{json, xml}, "", false},
+		{"params match", []string{"application/json;version=2"}, []string{"application/json;version=1", "application/json;version=2"}, "application/json;version=2", true},
+		{"params mismatch", []string{"application/json;version=3"}, []string{"application/json;version=1"}, "", false},
+		{"quoted params", []string{`text/plain;format="a,b"`}, []string{`text/plain;format="a,b"`}, `text/plain;format="a,b"`, true},
+		{"empty elements", []string{" , ,application/xml,"}, []string{json, xml}, xml, true},
+		{"empty header", []string{""}, []string{json, xml}, json, true},
+		{"type without subtype", []string{"application"}, []string{json, xml}, json, true},
+		{"invalid q ignored", []string{"application/json;q=x, application/xml;q=0.5"}, []string{json, xml}, json, true},
+		{"out of range q ignored", []string{"application/json;q=2, application/xml;q=0.5"}, []string{json, xml}, json, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, a := range tt.accept {
+				r.Header.Add("Accept", a)
+			}
+			got, ok := NegotiateContentType(r, tt.offers)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
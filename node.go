@@ -206,6 +206,32 @@ func (n *node) getRoute() *Route {
 	return nil
 }
 
+// paramNames returns the names of the params from the root down to n.
+func (n *node) paramNames() []string {
+	var names []string
+	for ; n != nil; n = n.parent {
+		if n.index != -1 && n.param != "" {
+			names = append([]string{n.param}, names...)
+		}
+	}
+	return names
+}
+
+// setParamsCount counts the params from the root down to n and its
+// sub-nodes, once n is added to a parent.
+func (n *node) setParamsCount() {
+	n.paramsCount = 0
+	if n.parent != nil {
+		n.paramsCount = n.parent.paramsCount
+	}
+	if n.index != -1 {
+		n.paramsCount++
+	}
+	for _, cn := range n.nodes {
+		cn.setParamsCount()
+	}
+}
+
 func (n *node) setParam(param string, index int, wildcard bool) {
 	if index < 0 {
 		return
@@ -299,11 +325,7 @@ func (n *node) addNode(on *node) {
 	if !found {
 		n.nodes = append(n.nodes, on)
 		on.parent = n
-		if on.index != -1 {
-			on.paramsCount = on.parent.paramsCount + 1
-		} else {
-			on.paramsCount = on.parent.paramsCount
-		}
+		on.setParamsCount()
 		if on.route != nil {
 			on.route.node = on
 		}
@@ -340,16 +362,16 @@ func (r *result) paramByIndex(idx int) (string, string) {
 
 	node := r.node
 
-	steps := node.paramsCount - idx - 1
-	if steps < 0 {
-		return "", ""
-	}
-
-	for i := 0; i < steps; {
+	for i := node.paramsCount - 1; node != nil; node = node.parent {
 		if node.index != -1 {
-			i++
+			if i == idx {
+				break
+			}
+			i--
 		}
-		node = node.parent
+	}
+	if node == nil {
+		return "", ""
 	}
 
 	ps := 0
@@ -394,11 +416,7 @@ walk:
 		}
 		cn.nodes = append(cn.nodes, con)
 		con.parent = cn
-		if con.index != -1 {
-			con.paramsCount = con.parent.paramsCount + 1
-		} else {
-			con.paramsCount = con.parent.paramsCount
-		}
+		con.setParamsCount()
 		if con.route != nil {
 			con.route.node = con
 		}
@@ -2,6 +2,7 @@ package mirango
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/mirango/defaults"
 	"github.com/mirango/framework"
@@ -112,9 +113,24 @@ type Operation struct {
 	mimeTypeIn    paramIn
 	mimeTypeParam string
 
-	returnsOnly bool
-	acceptsOnly bool
-	schemesOnly bool
+	validationMode validationMode
+	validators     []func(*Context, framework.ParamValues) error
+	conditional    func(*Context) (string, time.Time)
+	pagination     *Pagination
+	resource       *Resource
+
+	selectableFields []string
+	template         string
+	layout           string
+
+	returnsOnly    bool
+	acceptsOnly    bool
+	schemesOnly    bool
+	noCompression  bool
+	isIndex        bool
+	resourceEntity bool
+	fields         bool
+	hasLayout      bool
 }
 
 func NewOperation(h interface{}) *Operation {
@@ -283,6 +299,58 @@ func (o *Operation) getAllParams() {
 	o.params = params
 }
 
+// ValidationMode sets how the params of the Operation are validated.
+// If not set, the mode of the route is used.
+func (o *Operation) ValidationMode(mode validationMode) *Operation {
+	o.validationMode = mode
+	return o
+}
+
+func (o *Operation) GetValidationMode() validationMode {
+	if o.validationMode == validateInherit {
+		return VALIDATE_ALL
+	}
+	return o.validationMode
+}
+
+func (o *Operation) getValidationMode() {
+	if o.validationMode == validateInherit {
+		o.validationMode = o.route.validationMode
+	}
+}
+
+// Validate adds a validator that runs after the params are validated, to
+// check rules across several params. A returned *validation.Error is merged
+// as is, any other error is reported under the "_" key.
+func (o *Operation) Validate(f func(c *Context, v framework.ParamValues) error) *Operation {
+	if f != nil {
+		o.validators = append(o.validators, f)
+	}
+	return o
+}
+
+// getPathParams declares the params of the route pattern that are not
+// declared yet, and panics if a declared path param is not in the pattern or
+// a param of the pattern is declared elsewhere.
+func (o *Operation) getPathParams() {
+	names := o.route.node.paramNames()
+	for _, name := range names {
+		p := o.params.Get(name)
+		if p == nil {
+			o.params.Append(PathParam(name))
+			continue
+		}
+		if !p.IsIn(IN_PATH) {
+			panic(fmt.Sprintf("Detected a param of the path \"%s\" that is not declared in path: \"%s\".", o.route.GetFullPath(), name))
+		}
+	}
+	for name, p := range o.params.GetAll() {
+		if p.IsIn(IN_PATH) && !containsString(names, name) {
+			panic(fmt.Sprintf("Detected a path param that is not in the path \"%s\": \"%s\".", o.route.GetFullPath(), name))
+		}
+	}
+}
+
 func (o *Operation) Schemes(schemes ...string) *Operation {
 	o.schemes = append(o.schemes, schemes...)
 	o.schemesOnly = false
@@ -343,6 +411,12 @@ func (o *Operation) BodyParam(name string) *Param {
 	return p
 }
 
+func (o *Operation) CookieParam(name string) *Param {
+	p := CookieParam(name)
+	o.params.Append(p)
+	return p
+}
+
 func (o *Operation) GetMethods() []string {
 	return o.methods
 }
@@ -352,7 +426,7 @@ func (o *Operation) GetMiddleware() []Middleware {
 }
 
 func (o *Operation) getAllMiddleware() {
-	o.middleware = append(o.middleware, CheckReturns(o), CheckSchemes(o), CheckAccepts(o), CheckParams(o))
+	o.middleware = append(o.middleware, CheckReturns(o), CheckSchemes(o), CheckAccepts(o), CheckParams(o), CheckPreconditions(o), PaginationLinks(o))
 	o.middleware = middlewareUnion(o.middleware, o.route.middleware)
 }
 
@@ -398,8 +472,18 @@ func (o *Operation) GetFullPath() string {
 	return o.route.GetFullPath()
 }
 
+// NoCompression stops the responses of the Operation from being compressed,
+// as needed for event streams and binary downloads.
+func (o *Operation) NoCompression() *Operation {
+	o.noCompression = true
+	return o
+}
+
 func (o *Operation) ServeHTTP(c *Context) interface{} {
 	c.operation = o
+	if o.noCompression {
+		c.Response.disableCompression()
+	}
 	return o.handler.ServeHTTP(c)
 }
 
@@ -408,14 +492,18 @@ func (o *Operation) finalize() {
 	o.getAllAccepts()
 	o.getAllReturns()
 	o.getAllParams()
+	o.getPathParams()
+	o.getValidationMode()
 	o.getAllMiddleware()
 	o.Apply(o.route.presets...)
+	o.getPagination()
 	o.with()
 }
 
 func (o *Operation) Clone() *Operation {
 	no := NewOperation(o.handler)
 
+	no.name = o.name
 	no.methods = o.methods
 	no.schemes = o.schemes
 	no.accepts = o.accepts
@@ -424,10 +512,23 @@ func (o *Operation) Clone() *Operation {
 	no.params = o.params.Clone()
 	no.mimeTypeIn = o.mimeTypeIn
 	no.mimeTypeParam = o.mimeTypeParam
+	no.validationMode = o.validationMode
+	no.validators = o.validators
+	no.conditional = o.conditional
+	no.pagination = o.pagination
+	no.resource = o.resource
+	no.selectableFields = o.selectableFields
+	no.template = o.template
+	no.layout = o.layout
 	no.handler = o.handler
 	no.returnsOnly = o.returnsOnly
 	no.acceptsOnly = o.acceptsOnly
 	no.schemesOnly = o.schemesOnly
+	no.noCompression = o.noCompression
+	no.isIndex = o.isIndex
+	no.resourceEntity = o.resourceEntity
+	no.fields = o.fields
+	no.hasLayout = o.hasLayout
 
 	return no
 }
@@ -0,0 +1,297 @@
+package mirango
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mirango/framework"
+)
+
+// Pagination is a preset for list operations. It declares the limit and
+// offset, or cursor, query params and sets the Link headers of the response
+// to the first, previous, next and last pages.
+type Pagination struct {
+	// DefaultLimit is the limit of pages when the limit param is not set.
+	DefaultLimit int
+	// MaxLimit is the greatest limit accepted, if not 0.
+	MaxLimit int
+	// Cursor replaces the offset param by an opaque cursor param.
+	Cursor bool
+	// TotalCount sets the X-Total-Count header when the total is known.
+	TotalCount bool
+}
+
+// DefaultPagination returns the pagination used by operations paginated
+// without one.
+func DefaultPagination() *Pagination {
+	return &Pagination{
+		DefaultLimit: 20,
+		MaxLimit:     100,
+	}
+}
+
+// ApplyTo paginates the Operation. The params are declared when the
+// Operation is prepared, reusing those it already has.
+func (p *Pagination) ApplyTo(o *Operation) {
+	o.pagination = p
+}
+
+// declareParams declares the params of the pagination that the Operation
+// does not have already.
+func (p *Pagination) declareParams(o *Operation) {
+	if paginationParam(o, "limit") == nil {
+		limit := o.QueryParam("limit").As(framework.TYPE_INT).Min(1)
+		if p.MaxLimit > 0 {
+			limit.Max(float64(p.MaxLimit))
+		}
+	}
+	if p.Cursor {
+		if o.params.Get("cursor") == nil {
+			o.QueryParam("cursor")
+		}
+	} else if paginationParam(o, "offset") == nil {
+		o.QueryParam("offset").As(framework.TYPE_INT).Min(0)
+	}
+}
+
+// paginationParam returns the numeric param of the Operation with the given
+// name, or nil.
+func paginationParam(o *Operation, name string) *Param {
+	p := o.params.Get(name)
+	if p == nil {
+		return nil
+	}
+	switch p.GetAs() {
+	case framework.TYPE_INT, framework.TYPE_UINT:
+		return p
+	}
+	panic(fmt.Sprintf("Detected a pagination param that is not a number: \"%s\".", name))
+}
+
+// Paginate paginates the Operation with the given pagination, or the
+// default one.
+func (o *Operation) Paginate(p ...*Pagination) *Operation {
+	if len(p) > 0 && p[0] != nil {
+		return o.Apply(p[0])
+	}
+	return o.Apply(DefaultPagination())
+}
+
+// GetPagination returns the pagination of the Operation, or nil.
+func (o *Operation) GetPagination() *Pagination {
+	return o.pagination
+}
+
+// Pagination paginates the Index operations of the resources of the Route
+// and its sub-routes that are not paginated already.
+func (r *Route) Pagination(p *Pagination) *Route {
+	r.pagination = p
+	return r
+}
+
+func (r *Route) GetPagination() *Pagination {
+	if r.pagination == nil && r.parent != nil {
+		return r.parent.GetPagination()
+	}
+	return r.pagination
+}
+
+func (o *Operation) getPagination() {
+	if o.isIndex && o.pagination == nil {
+		if p := o.route.GetPagination(); p != nil {
+			o.Apply(p)
+		}
+	}
+	if o.pagination != nil {
+		o.pagination.declareParams(o)
+	}
+}
+
+// Page is the page of a list requested by the client. Handlers read its
+// bounds, and set the total or the cursors of the adjacent pages for the
+// Link headers to be set.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string
+
+	total      int
+	hasTotal   bool
+	next       string
+	prev       string
+	cursorType *ValueType
+}
+
+// Page returns the page requested, or nil if the Operation is not
+// paginated.
+func (c *Context) Page() *Page {
+	if c.page != nil || c.operation == nil || c.operation.pagination == nil {
+		return c.page
+	}
+	p := c.operation.pagination
+	pg := &Page{
+		Limit: p.DefaultLimit,
+	}
+	if c.IsSet("limit") {
+		if n, err := strconv.Atoi(c.Param("limit").String()); err == nil {
+			pg.Limit = n
+		}
+	}
+	if p.MaxLimit > 0 && pg.Limit > p.MaxLimit {
+		pg.Limit = p.MaxLimit
+	}
+	if p.Cursor {
+		if c.IsSet("cursor") {
+			pg.Cursor = c.Param("cursor").String()
+		}
+		if cp := c.operation.params.Get("cursor"); cp != nil && cp.typeName != "" && c.types != nil {
+			pg.cursorType = c.types.Get(cp.typeName)
+		}
+	} else if c.IsSet("offset") {
+		if n, err := strconv.Atoi(c.Param("offset").String()); err == nil && n > 0 {
+			pg.Offset = n
+		}
+	}
+	c.page = pg
+	return pg
+}
+
+// SetTotal sets the total number of items, giving the last page.
+func (pg *Page) SetTotal(total int) {
+	pg.total = total
+	pg.hasTotal = true
+}
+
+// SetCursors sets the cursors of the next and previous pages, formatted with
+// the ValueType of the cursor param if it has one. A nil or empty cursor
+// means there is no such page.
+func (pg *Page) SetCursors(next interface{}, prev interface{}) {
+	pg.next = pg.formatCursor(next)
+	pg.prev = pg.formatCursor(prev)
+}
+
+func (pg *Page) formatCursor(cursor interface{}) string {
+	if cursor == nil {
+		return ""
+	}
+	return pg.cursorType.format(cursor)
+}
+
+// Total returns the total set by the handler.
+func (pg *Page) Total() (int, bool) {
+	return pg.total, pg.hasTotal
+}
+
+func pageURL(u *url.URL, set map[string]string) string {
+	q := u.Query()
+	for k, v := range set {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	nu := url.URL{Path: u.Path, RawQuery: q.Encode()}
+	return nu.String()
+}
+
+// linkList returns the relations and URLs of the pages adjacent to the
+// page, given the result of the handler to find out if there is a next page
+// when the total is unknown.
+func (pg *Page) linkList(p *Pagination, u *url.URL, result interface{}) [][2]string {
+	var links [][2]string
+	add := func(rel string, set map[string]string) {
+		links = append(links, [2]string{rel, pageURL(u, set)})
+	}
+	limit := strconv.Itoa(pg.Limit)
+
+	if p.Cursor {
+		add("first", map[string]string{"limit": limit, "cursor": ""})
+		if pg.prev != "" {
+			add("prev", map[string]string{"limit": limit, "cursor": pg.prev})
+		}
+		if pg.next != "" {
+			add("next", map[string]string{"limit": limit, "cursor": pg.next})
+		}
+		return links
+	}
+
+	offset := func(n int) map[string]string {
+		return map[string]string{"limit": limit, "offset": strconv.Itoa(n)}
+	}
+	add("first", offset(0))
+	if pg.Offset > 0 {
+		prev := pg.Offset - pg.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		add("prev", offset(prev))
+	}
+	if pg.hasTotal {
+		if pg.Offset+pg.Limit < pg.total {
+			add("next", offset(pg.Offset+pg.Limit))
+		}
+		last := 0
+		if pg.total > 0 {
+			last = (pg.total - 1) / pg.Limit * pg.Limit
+		}
+		add("last", offset(last))
+	} else if isFullPage(result, pg.Limit) {
+		add("next", offset(pg.Offset+pg.Limit))
+	}
+	return links
+}
+
+// links returns the Link header value of the page.
+func (pg *Page) links(p *Pagination, u *url.URL, result interface{}) string {
+	var links []string
+	for _, l := range pg.linkList(p, u, result) {
+		links = append(links, "<"+l[1]+`>; rel="`+l[0]+`"`)
+	}
+	return strings.Join(links, ", ")
+}
+
+// isFullPage reports whether the result, or the body of a Responder, is a
+// list of at least limit items.
+func isFullPage(result interface{}, limit int) bool {
+	if r, ok := result.(Responder); ok {
+		result = r.Body()
+	}
+	v := reflect.ValueOf(result)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Len() >= limit
+}
+
+func PaginationLinks(o *Operation) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return HandlerFunc(func(c *Context) interface{} {
+			if o.pagination == nil {
+				return next.ServeHTTP(c)
+			}
+			result := next.ServeHTTP(c)
+			pg := c.Page()
+			if pg == nil || pg.Limit <= 0 {
+				return result
+			}
+			if _, ok := result.(error); ok {
+				return result
+			}
+			if r, ok := result.(Responder); ok && (r.StatusCode() < 200 || r.StatusCode() > 299) {
+				return result
+			}
+			h := c.Response.Header()
+			if links := pg.links(o.pagination, c.Request.URL, result); links != "" {
+				h.Add("Link", links)
+			}
+			if o.pagination.TotalCount && pg.hasTotal {
+				h.Set("X-Total-Count", strconv.Itoa(pg.total))
+			}
+			return result
+		})
+	})
+}
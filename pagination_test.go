@@ -0,0 +1,74 @@
+package mirango
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mirango/framework"
+)
+
+func TestPaginationLinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		handler func(c *Context) interface{}
+		link    string
+	}{
+		{"total", "?offset=20&limit=10&x=1", func(c *Context) interface{} {
+			c.Page().SetTotal(45)
+			return []int{1}
+		}, `</items?limit=10&offset=0&x=1>; rel="first", </items?limit=10&offset=10&x=1>; rel="prev", </items?limit=10&offset=30&x=1>; rel="next", </items?limit=10&offset=40&x=1>; rel="last"`},
+		{"last page", "?offset=40&limit=10", func(c *Context) interface{} {
+			c.Page().SetTotal(45)
+			return []int{1}
+		}, `</items?limit=10&offset=0>; rel="first", </items?limit=10&offset=30>; rel="prev", </items?limit=10&offset=40>; rel="last"`},
+		{"full page", "?limit=2", func(c *Context) interface{} { return []int{1, 2} }, `</items?limit=2&offset=0>; rel="first", </items?limit=2&offset=2>; rel="next"`},
+		{"partial page", "?limit=2", func(c *Context) interface{} { return []int{1} }, `</items?limit=2&offset=0>; rel="first"`},
+		{"full page responder", "?limit=2", func(c *Context) interface{} { return Respond(200, []int{1, 2}) }, `</items?limit=2&offset=0>; rel="first", </items?limit=2&offset=2>; rel="next"`},
+		{"error responder", "?limit=2", func(c *Context) interface{} { return NotFound([]int{1, 2}) }, ""},
+		{"default limit", "", func(c *Context) interface{} { return nil }, `</items?limit=10&offset=0>; rel="first"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New("/")
+			m.Branch("items").GET(tt.handler).Paginate(&Pagination{DefaultLimit: 10, MaxLimit: 50})
+			m.Prepare()
+
+			r := httptest.NewRequest("GET", "/items"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, r)
+			if got := rec.Header().Get("Link"); got != tt.link {
+				t.Errorf("Link %q, want %q", got, tt.link)
+			}
+		})
+	}
+}
+
+func TestPaginationParams(t *testing.T) {
+	m := New("/")
+	o := m.Branch("items").GET(func(c *Context) interface{} { return nil }).
+		Params(QueryParam("limit").As(framework.TYPE_UINT).Max(10)).
+		Paginate()
+	m.Prepare()
+	if p := o.GetParams().Get("limit"); p.GetAs() != framework.TYPE_UINT {
+		t.Errorf("limit declared as %v", p.GetAs())
+	}
+	if p := o.GetParams().Get("offset"); p == nil || p.GetAs() != framework.TYPE_INT {
+		t.Errorf("offset declared as %v", p)
+	}
+
+	for _, name := range []string{"limit", "offset"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic for a string %s param", name)
+				}
+			}()
+			m := New("/")
+			m.Branch("items").GET(func(c *Context) interface{} { return nil }).
+				Params(QueryParam(name)).
+				Paginate()
+			m.Prepare()
+		}()
+	}
+}
@@ -20,6 +20,19 @@ const (
 	IN_QUERY
 	IN_HEADER
 	IN_BODY
+	IN_COOKIE
+)
+
+type validationMode int
+
+const (
+	validateInherit validationMode = iota
+	// VALIDATE_ALL reports all the errors of all the params.
+	VALIDATE_ALL
+	// VALIDATE_FIRST reports the first error of each param.
+	VALIDATE_FIRST
+	// VALIDATE_ONE stops at the first error encountered.
+	VALIDATE_ONE
 )
 
 func NewParams() *Params {
@@ -53,6 +66,13 @@ func (p *Params) Append(params ...*Param) {
 		if _, ok := p.params[name]; ok {
 			panic(fmt.Sprintf("Detected 2 params with the same name: \"%s\".", name))
 		}
+		for _, pa := range p.params {
+			for _, n := range params[i].names() {
+				if containsString(pa.names(), n) {
+					panic(fmt.Sprintf("Detected 2 params with the same name: \"%s\".", n))
+				}
+			}
+		}
 		if params[i].isInBody {
 			p.containsBodyParams = true
 		}
@@ -84,13 +104,33 @@ func (p *Params) Len() int {
 	return len(p.params)
 }
 
+// except returns the params whose names are not in names.
+func (p *Params) except(names map[string]bool) *Params {
+	if len(names) == 0 {
+		return p
+	}
+	params := NewParams()
+	for _, pa := range p.params {
+		if !names[pa.name] {
+			params.params[pa.name] = pa
+		}
+	}
+	return params
+}
+
 // Param
 type Param struct {
 	name          string
 	validators    []validation.Validator
+	constraints   constraints
 	def           interface{}
 	as            framework.ValueType
+	typeName      string
+	aliases       []string
+	deprecation   string
+	isDeprecated  bool
 	strSep        string
+	style         paramStyle
 	isRequired    bool
 	isMultiple    bool
 	isFile        bool
@@ -98,6 +138,8 @@ type Param struct {
 	isInQuery     bool
 	isInHeader    bool
 	isInBody      bool
+	isInCookie    bool
+	signingKeys   [][]byte
 	preprocessor  func(*validation.Value)
 	postprocessor func(*validation.Value)
 }
@@ -125,6 +167,10 @@ func BodyParam(name string) *Param {
 	return NewParam(name).In(IN_BODY)
 }
 
+func CookieParam(name string) *Param {
+	return NewParam(name).In(IN_COOKIE)
+}
+
 func (p *Param) Name(name string) *Param {
 	p.name = name
 	return p
@@ -171,14 +217,48 @@ func (p *Param) As(as framework.ValueType) *Param {
 		as == framework.TYPE_STRUCT {
 
 		p.as = as
+		p.typeName = ""
 	}
 	return p
 }
 
+// AsType declares the Param as the ValueType registered on Mirango with the
+// given name, such as "uuid".
+func (p *Param) AsType(name string) *Param {
+	if name == "" {
+		panic("Detected an empty value type name.")
+	}
+	p.as = framework.TYPE_STRING
+	p.typeName = name
+	return p
+}
+
+// GetTypeName returns the name of the ValueType of the Param, if declared
+// with one.
+func (p *Param) GetTypeName() string {
+	return p.typeName
+}
+
 func (p *Param) GetAs() framework.ValueType {
 	return p.as
 }
 
+// Style sets how the Param is serialized in the query.
+func (p *Param) Style(style paramStyle) *Param {
+	p.style = style
+	return p
+}
+
+// DeepObject makes a query Param collect bracketed keys such as
+// filter[status]=open&filter[owner][id]=7 into nested values.
+func (p *Param) DeepObject() *Param {
+	return p.Style(STYLE_DEEP_OBJECT)
+}
+
+func (p *Param) GetStyle() paramStyle {
+	return p.style
+}
+
 // If a Param is in path then it is required.
 func (p *Param) In(in ...paramIn) *Param {
 	for _, i := range in {
@@ -192,6 +272,8 @@ func (p *Param) In(in ...paramIn) *Param {
 			p.isInHeader = true
 		case IN_BODY:
 			p.isInBody = true
+		case IN_COOKIE:
+			p.isInCookie = true
 		}
 	}
 	return p
@@ -201,9 +283,15 @@ func (p *Param) Clone() *Param {
 	param := NewParam(p.name)
 
 	param.validators = p.validators
+	param.constraints = p.constraints
 	param.def = p.def
 	param.as = p.as
+	param.typeName = p.typeName
+	param.aliases = p.aliases
+	param.deprecation = p.deprecation
+	param.isDeprecated = p.isDeprecated
 	param.strSep = p.strSep
+	param.style = p.style
 	param.isRequired = p.isRequired
 	param.isMultiple = p.isMultiple
 	param.isFile = p.isFile
@@ -211,6 +299,8 @@ func (p *Param) Clone() *Param {
 	param.isInQuery = p.isInQuery
 	param.isInHeader = p.isInHeader
 	param.isInBody = p.isInBody
+	param.isInCookie = p.isInCookie
+	param.signingKeys = p.signingKeys
 	param.preprocessor = p.preprocessor
 	param.postprocessor = p.postprocessor
 
@@ -237,11 +327,27 @@ func (p *Param) IsIn(in ...paramIn) bool {
 			if !p.isInBody {
 				return false
 			}
+		case IN_COOKIE:
+			if !p.isInCookie {
+				return false
+			}
 		}
 	}
 	return true
 }
 
+// Signed makes a cookie Param verify the signature of its value with one of
+// the given keys. The first key is expected to be the current one, the others
+// are accepted to allow key rotation.
+func (p *Param) Signed(keys ...[]byte) *Param {
+	p.signingKeys = keys
+	return p
+}
+
+func (p *Param) IsSigned() bool {
+	return len(p.signingKeys) > 0
+}
+
 // Must sets the validators to use.
 func (p *Param) Validators(validators ...validation.Validator) *Param {
 	p.validators = nil
@@ -253,8 +359,14 @@ func (p *Param) Validators(validators ...validation.Validator) *Param {
 	return p
 }
 
+// GetValidators returns the validators enforcing the declared constraints
+// followed by the ones set with Validators.
+func (p *Param) GetValidators() []validation.Validator {
+	return append(p.constraintValidators(), p.validators...)
+}
+
 func (p *Param) validate(c framework.Context, v framework.ParamValue) error {
-	for _, va := range p.validators {
+	for _, va := range p.GetValidators() {
 		err := va.Validate(c, v)
 		if err != nil {
 			return err
@@ -277,7 +389,7 @@ func (p *Param) Validate(c framework.Context, v framework.ParamValue) *validatio
 func (p *Param) validateAll(c framework.Context, v framework.ParamValue) []error {
 	var errs []error
 	var err error
-	for _, va := range p.validators {
+	for _, va := range p.GetValidators() {
 		err = va.Validate(c, v)
 		if err != nil {
 			errs = append(errs, err)
@@ -344,3 +456,14 @@ func (pa *Params) ValidateAll(c framework.Context, vs framework.ParamValues) *va
 	}
 	return errs
 }
+
+// ValidateWith validates the params using the given mode.
+func (pa *Params) ValidateWith(mode validationMode, c framework.Context, vs framework.ParamValues) *validation.Error {
+	switch mode {
+	case VALIDATE_ONE:
+		return pa.Validate(c, vs)
+	case VALIDATE_FIRST:
+		return pa.ValidateFirst(c, vs)
+	}
+	return pa.ValidateAll(c, vs)
+}
@@ -0,0 +1,172 @@
+package mirango
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mirango/validation"
+)
+
+const (
+	MIME_PROBLEM_JSON = "application/problem+json"
+	MIME_PROBLEM_XML  = "application/problem+xml"
+)
+
+// Problem is an RFC 7807 problem details object. Extensions are encoded as
+// members of the problem, next to the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Extension sets an extension member of the Problem.
+func (p *Problem) Extension(name string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[name] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+func (p *Problem) members() map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return m
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.members())
+}
+
+// MarshalXML encodes the Problem in the urn:ietf:rfc:7807 namespace.
+// Extension values are encoded with encoding/xml, or as text if they can
+// not be.
+func (p *Problem) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}
+	start.Attr = nil
+	err := e.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+	members := p.members()
+	var names []string
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		el := xml.StartElement{Name: xml.Name{Local: name}}
+		err = e.EncodeElement(members[name], el)
+		if err != nil {
+			err = e.EncodeElement(fmt.Sprint(members[name]), el)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ProblemRenderer turns the errors returned by handlers and middleware into
+// problems.
+type ProblemRenderer interface {
+	Problem(c *Context, status int, err error) *Problem
+}
+
+type ProblemRendererFunc func(c *Context, status int, err error) *Problem
+
+func (f ProblemRendererFunc) Problem(c *Context, status int, err error) *Problem {
+	return f(c, status, err)
+}
+
+// DefaultProblemRenderer reports validation errors as an "invalid-params"
+// extension and hides the message of errors without a status code.
+var DefaultProblemRenderer ProblemRenderer = ProblemRendererFunc(func(c *Context, status int, err error) *Problem {
+	if p, ok := err.(*Problem); ok {
+		return p
+	}
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	p := NewProblem(status, "")
+	switch t := err.(type) {
+	case *validation.Error:
+		p.Detail = c.Message(MSG_INVALID_PARAMS)
+		p.Extension("invalid-params", t)
+	default:
+		if _, ok := errorStatus(err); ok {
+			p.Detail = err.Error()
+		}
+	}
+	if c.Request != nil {
+		p.Instance = c.Request.RequestURI
+	}
+	return p
+})
+
+// renderProblem writes the problem as problem+xml if the negotiated encoding
+// is XML, and as problem+json otherwise.
+func (w *Response) renderProblem(p *Problem) error {
+	mimeType := MIME_PROBLEM_JSON
+	_, subtype := parseMediaType(w.encoding)
+	if subtype == "xml" || strings.HasSuffix(subtype, "+xml") {
+		mimeType = MIME_PROBLEM_XML
+	}
+	b, err := encode(w, p, mimeType)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", mimeType)
+	if p.Status != 0 {
+		w.WriteHeader(p.Status)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -8,13 +8,19 @@ import (
 
 type Request struct {
 	*http.Request
-	input    framework.ParamValues
-	sessions framework.Sessions
+	input      framework.ParamValues
+	objects    map[string]interface{}
+	parsed     map[string]interface{}
+	pathValues map[string]string
+	sessions   framework.Sessions
 }
 
 func NewRequest(r *http.Request) *Request {
 	return &Request{
-		Request: r,
+		Request:    r,
+		objects:    map[string]interface{}{},
+		parsed:     map[string]interface{}{},
+		pathValues: map[string]string{},
 	}
 }
 
@@ -29,6 +35,18 @@ func (r *Request) IsSet(name string) bool {
 	return p != nil
 }
 
+// ParamObject returns the nested maps and slices parsed for a deep-object
+// param.
+func (r *Request) ParamObject(name string) interface{} {
+	return r.objects[name]
+}
+
+// BindParam assigns the value of a deep-object param to v, which must be a
+// pointer to a struct, map or slice.
+func (r *Request) BindParam(name string, v interface{}) error {
+	return bindDeepValue(r.objects[name], v)
+}
+
 func (r *Request) Params(names ...string) framework.ParamValues {
 	if len(names) == 0 {
 		return r.input
@@ -41,6 +41,8 @@ type Resource struct {
 	Patch       *Operation
 	Delete      *Operation
 	re          interface{}
+	paramName   string
+	children    []*Resource
 }
 
 func NewResource(name string, re interface{}) *Resource {
@@ -72,6 +74,7 @@ func NewResourceNested(name string, re interface{}, cb func(*Resource)) *Resourc
 	if t, ok := re.(resourceIndex); ok {
 		foundOne = true
 		index = route.GET(t.Index).Name("get_" + inflector.Pluralize(name))
+		index.isIndex = true
 	}
 
 	if t, ok := re.(resourceCreate); ok {
@@ -114,8 +117,11 @@ func NewResourceNested(name string, re interface{}, cb func(*Resource)) *Resourc
 		Patch:       patch,
 		Delete:      delete,
 		re:          re,
+		paramName:   paramName,
 	}
 
+	resource.bindOperations()
+
 	if cb != nil {
 		cb(resource)
 	}
@@ -130,6 +136,7 @@ func (r *Resource) Resource(name string, re interface{}) *Resource {
 func (r *Resource) ResourceNested(name string, re interface{}, cb func(*Resource)) *Resource {
 	resource := NewResource(name, re)
 	resource = r.EntityRoute.AddResource(resource)
+	r.children = append(r.children, resource)
 
 	if cb != nil {
 		cb(resource)
@@ -151,6 +158,7 @@ func (r *Resource) AddResourceNested(resource *Resource, cb func(*Resource)) *Re
 
 	resource.Route = r.Route.AddRoute(resource.Route)
 	resource.EntityRoute = resource.Route.AddRoute(resource.EntityRoute)
+	resource.bindOperations()
 
 	if cb != nil {
 		cb(resource)
@@ -159,8 +167,30 @@ func (r *Resource) AddResourceNested(resource *Resource, cb func(*Resource)) *Re
 	return resource
 }
 
+// bindOperations points the Resource to the operations of its routes, which
+// are cloned when the routes are added to others.
+func (r *Resource) bindOperations() {
+	bind := func(o **Operation, route *Route, method string, entity bool) {
+		if *o == nil {
+			return
+		}
+		if ro := route.operations.GetByMethod(method); ro != nil {
+			*o = ro
+		}
+		(*o).resource = r
+		(*o).resourceEntity = entity
+	}
+	bind(&r.Index, r.Route, "GET", false)
+	bind(&r.Create, r.Route, "POST", false)
+	bind(&r.Get, r.EntityRoute, "GET", true)
+	bind(&r.Update, r.EntityRoute, "PUT", true)
+	bind(&r.Patch, r.EntityRoute, "PATCH", true)
+	bind(&r.Delete, r.EntityRoute, "DELETE", true)
+}
+
 func (r *Resource) Clone() *Resource {
 	resource := NewResource(r.Name, r.re)
+	resource.children = r.children
 	return resource
 }
 
@@ -192,6 +222,7 @@ func (r *Route) AddResourceNested(resource *Resource, cb func(*Resource)) *Resou
 
 	resource.Route = r.AddRoute(resource.Route)
 	resource.EntityRoute = resource.Route.AddRoute(resource.EntityRoute)
+	resource.bindOperations()
 
 	if cb != nil {
 		cb(resource)
@@ -0,0 +1,121 @@
+package mirango
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// Responder is implemented by handler return values that set the status
+// code and headers of the response along with its body.
+type Responder interface {
+	StatusCode() int
+	Headers() http.Header
+	Body() interface{}
+}
+
+// Reply is a Responder returned by handlers.
+type Reply struct {
+	status int
+	header http.Header
+	body   interface{}
+}
+
+// Respond returns a Reply with the given status code and body.
+func Respond(status int, body interface{}) *Reply {
+	return &Reply{
+		status: status,
+		header: http.Header{},
+		body:   body,
+	}
+}
+
+func Created(body interface{}, location string) *Reply {
+	return Respond(http.StatusCreated, body).Header("Location", location)
+}
+
+func Accepted(body interface{}) *Reply {
+	return Respond(http.StatusAccepted, body)
+}
+
+func NoContent() *Reply {
+	return Respond(http.StatusNoContent, nil)
+}
+
+func NotFound(body interface{}) *Reply {
+	return Respond(http.StatusNotFound, body)
+}
+
+func Conflict(body interface{}) *Reply {
+	return Respond(http.StatusConflict, body)
+}
+
+// Header adds a header to the Reply.
+func (r *Reply) Header(key string, value string) *Reply {
+	r.header.Add(key, value)
+	return r
+}
+
+func (r *Reply) StatusCode() int {
+	return r.status
+}
+
+func (r *Reply) Headers() http.Header {
+	return r.header
+}
+
+func (r *Reply) Body() interface{} {
+	return r.body
+}
+
+// statusOf returns the status code carried by an error through a
+// StatusCode, Status or Code method, if it is a valid one.
+func statusOf(v interface{}) (int, bool) {
+	var status int
+	switch t := v.(type) {
+	case interface{ StatusCode() int }:
+		status = t.StatusCode()
+	case interface{ Status() int }:
+		status = t.Status()
+	case interface{ Code() int }:
+		status = t.Code()
+	default:
+		return 0, false
+	}
+	if status < 100 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+// errorStatus returns the status code carried by err, either through a
+// method or through an int field of the same name, as errors.Error does.
+func errorStatus(err error) (int, bool) {
+	if status, ok := statusOf(err); ok {
+		return status, true
+	}
+
+	rv := reflect.ValueOf(err)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return 0, false
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return 0, false
+	}
+	for _, name := range []string{"StatusCode", "Status", "Code"} {
+		f := rv.FieldByName(name)
+		if !f.IsValid() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			status := int(f.Int())
+			if status >= 100 && status < 600 {
+				return status, true
+			}
+		}
+	}
+	return 0, false
+}
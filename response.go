@@ -1,19 +1,34 @@
 package mirango
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/mirango/defaults"
 	"github.com/mirango/framework"
+	"github.com/mirango/validation"
 )
 
 type Response struct {
 	http.ResponseWriter
+	ctx           context.Context
+	request       *http.Request
+	etags         etagMode
 	statusCode    int
 	contentLength int
 	encoding      string
 	encoders      framework.Encoders
+	events        []*EventWriter
 }
 
 func NewResponse(w http.ResponseWriter, encoders framework.Encoders) *Response {
@@ -66,6 +81,10 @@ func (w *Response) StatusCode() int {
 }
 
 func (w *Response) Write(bytes []byte) (int, error) {
+	if w.statusCode == 0 {
+		// the ResponseWriter writes a 200 OK before the first write
+		w.statusCode = http.StatusOK
+	}
 	written, err := w.ResponseWriter.Write(bytes)
 	w.contentLength += written
 	return written, err
@@ -81,71 +100,171 @@ func (w *Response) Flush() {
 	}
 }
 
-func (w *Response) CloseNotify() <-chan bool {
-	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+// Hijack lets the caller take over the connection, as done by WebSocket
+// operations.
+func (w *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil {
+		w.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
 }
 
-func (w *Response) StreamAsJson(status int, d time.Duration, f func(int64) (interface{}, bool)) error {
-	var i int64 = 0
-	var err error
-	for {
-		e, stop := f(i)
-		if e != nil {
-			eerr := w.WriteJson(status, e, framework.MIME_JSON)
-			if eerr != nil {
-				err = eerr
-				break
-			}
-			_, eerr = w.Write([]byte("\n"))
-			w.Flush()
-			i++
-		}
-		if stop {
-			break
-		}
-		time.Sleep(d)
+// CloseNotify returns the channel of the http.CloseNotifier of the
+// ResponseWriter, or nil if it is not one.
+func (w *Response) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
 	}
+	return nil
+}
 
-	return err
+func (w *Response) StreamAsJson(status int, d time.Duration, f func(int64) (interface{}, bool)) error {
+	return w.streamPoll(w.newStreamWriter(status, streamNDJSON), d, f)
 }
 
 func (w *Response) StreamAsXml(status int, d time.Duration, f func(int64) (interface{}, bool)) error {
-	var i int64 = 0
-	var err error
-	for {
-		e, stop := f(i)
-		if e != nil {
-			eerr := w.WriteAsXml(status, e, i == 0)
-			if eerr != nil {
-				err = eerr
-				break
-			}
-			_, eerr = w.Write([]byte("\n"))
-			w.Flush()
-			i++
-		}
-		if stop {
-			break
-		}
-		time.Sleep(d)
-	}
-
-	return err
+	return w.streamPoll(w.newStreamWriter(status, streamXML), d, f)
 }
 
+// Render writes the value returned by a handler:
+//
+//	Responder: its status code, headers and body
+//	nil: 204 No Content, unless the handler wrote the response
+//	*Content, *os.File and io.ReadSeeker: served with Range support, with
+//	a 2xx Responder status only
+//	[]byte, string and io.Reader: written as is
+//	*validation.Error: 400 Bad Request with the encoded errors
+//	error: the status code it carries, or 500, with the encoded error, or
+//	as a problem if the route has a ProblemRenderer
+//	any other value: encoded, with 200 OK
+//
+// When text/html is negotiated for an operation with a template, nil and
+// other values are rendered with the template instead, unless the status is
+// not a 2xx with content or a Responder returned nil.
 func (w *Response) Render(c *Context, data interface{}) error {
-	encoder := w.encoders.Get(w.encoding)
-	if encoder != nil {
-		// check data type
-		b, err := encoder.Encode(data)
-		if err != nil {
+	status := 0
+	if r, ok := data.(Responder); ok {
+		status = r.StatusCode()
+		for k, v := range r.Headers() {
+			w.Header()[k] = v
+		}
+		data = r.Body()
+	}
+
+	switch t := data.(type) {
+	case nil:
+		if status == 0 && (w.statusCode != 0 || w.contentLength > 0) {
+			// the handler wrote the response itself
+			return nil
+		}
+		if status == 0 {
+			if ok, err := w.renderTemplate(c, status, nil); ok {
+				return err
+			}
+		}
+		if status == 0 {
+			status = http.StatusNoContent
+		}
+		w.WriteHeader(status)
+		return nil
+	case []byte:
+		if w.Header().Get(framework.HEADER_ContentType) == "" {
+			w.Header().Set(framework.HEADER_ContentType, "application/octet-stream")
+		}
+		if w.notModified(status, t) {
+			return nil
+		}
+		w.WriteHeader(status)
+		_, err := w.Write(t)
+		return err
+	case string:
+		if w.Header().Get(framework.HEADER_ContentType) == "" {
+			w.Header().Set(framework.HEADER_ContentType, "text/plain; charset=utf-8")
+		}
+		if w.notModified(status, []byte(t)) {
+			return nil
+		}
+		w.WriteHeader(status)
+		_, err := w.Write([]byte(t))
+		return err
+	case *Content:
+		return t.serve(w, status)
+	case *os.File:
+		return ServeFile(t).serve(w, status)
+	case io.ReadSeeker:
+		return ServeContent("", time.Time{}, t).serve(w, status)
+	case io.Reader:
+		if rc, ok := t.(io.Closer); ok {
+			defer rc.Close()
+		}
+		if w.Header().Get(framework.HEADER_ContentType) == "" {
+			w.Header().Set(framework.HEADER_ContentType, "application/octet-stream")
+		}
+		w.WriteHeader(status)
+		_, err := io.Copy(w, t)
+		return err
+	case *validation.Error:
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		if pr := c.getProblemRenderer(); pr != nil {
+			return w.renderProblem(pr.Problem(c, status, t))
+		}
+	case error:
+		if status == 0 {
+			status = http.StatusInternalServerError
+			if s, ok := errorStatus(t); ok {
+				status = s
+			} else {
+				data = map[string]string{"error": http.StatusText(status)}
+			}
+		}
+		if pr := c.getProblemRenderer(); pr != nil {
+			return w.renderProblem(pr.Problem(c, status, t))
+		}
+	default:
+		if ok, err := w.renderTemplate(c, status, data); ok {
 			return err
 		}
-		w.WriteHeader(200)
-		_, err = w.Write(b)
+		de, isDocument := w.encoders.Get(w.encoding).(documentEncoder)
+		isDocument = isDocument && c.operation != nil && c.operation.resource != nil
+		if fields := c.selectedFields(); fields != nil && selectsFields(w.encoding) {
+			if isDocument {
+				fields.keepIDs()
+			}
+			data = selectFields(data, fields)
+		}
+		if isDocument {
+			doc, err := de.document(c, c.operation.resource, c.operation.resourceEntity, data)
+			if err != nil {
+				return err
+			}
+			data = doc
+		}
+	}
+
+	encoding := w.encoding
+	if encoding == "" {
+		encoding = defaults.MimeType
+	}
+	b, err := encode(w, data, encoding)
+	if err != nil {
 		return err
 	}
-	return nil
+	if w.Header().Get(framework.HEADER_ContentType) == "" {
+		w.Header().Set(framework.HEADER_ContentType, encoding)
+	}
+	if w.notModified(status, b) {
+		return nil
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
 }
 
 func (w *Response) Stream(status int, d time.Duration, f func(int64) (interface{}, bool)) error {
@@ -153,16 +272,11 @@ func (w *Response) Stream(status int, d time.Duration, f func(int64) (interface{
 		return nil
 	}
 
-	switch w.encoding {
-	case framework.MIME_JSON:
-		return w.StreamAsJson(status, d, f)
-	case framework.MIME_XML:
-		return w.StreamAsXml(status, d, f)
-	}
-
-	return nil
+	return w.streamPoll(w.newStreamWriter(status, streamFormatOf(w.encoding)), d, f)
 }
 
+// WriteEntity writes value encoded as encoding. If it cannot be encoded, a
+// 500 Internal Server Error is written and the error is returned.
 func WriteEntity(w http.ResponseWriter, status int, value interface{}, encoding string) (int, error) {
 	if value == nil {
 		return 0, nil
@@ -175,27 +289,64 @@ func WriteEntity(w http.ResponseWriter, status int, value interface{}, encoding
 		return WriteAsXml(w, status, value, true)
 	}
 
-	return 0, nil
+	output, err := encode(w, value, encoding)
+	if err != nil {
+		return writeEncodeError(w, err)
+	}
+	w.Header().Set(framework.HEADER_ContentType, encoding)
+	w.WriteHeader(status)
+	return w.Write(output)
 }
 
-func WriteAsXml(w http.ResponseWriter, status int, value interface{}, writeHeader bool) (int, error) {
-	var output []byte
-	var err error
+// writeEncodeError writes a 500 Internal Server Error, without the error
+// that may reveal internals, and returns the error.
+func writeEncodeError(w http.ResponseWriter, err error) (int, error) {
+	w.WriteHeader(http.StatusInternalServerError)
+	return 0, err
+}
 
+// encode encodes value with the encoder registered for the given media type
+// if w is a *Response, and falls back to encoding/json, encoding/xml, CSV and
+// MessagePack. It fails if none encodes the media type.
+func encode(w http.ResponseWriter, value interface{}, mimeType string) ([]byte, error) {
+	if res, ok := w.(*Response); ok {
+		if encoder := res.encoders.Get(mimeType); encoder != nil {
+			return encoder.Encode(value)
+		}
+	}
+
+	_, subtype := parseMediaType(strings.Split(mimeType, ";")[0])
+	switch {
+	case subtype == "json" || strings.HasSuffix(subtype, "+json"):
+		return json.Marshal(value)
+	case subtype == "xml" || strings.HasSuffix(subtype, "+xml"):
+		return xml.Marshal(value)
+	case subtype == "csv":
+		return CSVCodec{}.Encode(value)
+	case subtype == "msgpack" || subtype == "x-msgpack":
+		return marshalMsgpack(value)
+	}
+	return nil, fmt.Errorf("no encoder found for %s", mimeType)
+}
+
+func WriteAsXml(w http.ResponseWriter, status int, value interface{}, writeHeader bool) (int, error) {
 	if value == nil {
 		return 0, nil
 	}
 
+	output, err := encode(w, value, framework.MIME_XML)
 	if err != nil {
-		return WriteString(w, http.StatusInternalServerError, err.Error())
+		return writeEncodeError(w, err)
 	}
 	w.Header().Set(framework.HEADER_ContentType, framework.MIME_XML)
 	w.WriteHeader(status)
-	if writeHeader {
+	if writeHeader && !bytes.HasPrefix(output, []byte("<?xml")) {
 		cl, err := w.Write([]byte(xml.Header))
 		if err != nil {
 			return cl, err
 		}
+		n, err := w.Write(output)
+		return cl + n, err
 	}
 	return w.Write(output)
 
@@ -206,15 +357,13 @@ func WriteAsJson(w http.ResponseWriter, status int, value interface{}) (int, err
 }
 
 func WriteJson(w http.ResponseWriter, status int, value interface{}, contentType string) (int, error) {
-	var output []byte
-	var err error
-
 	if value == nil {
 		return 0, nil
 	}
 
+	output, err := encode(w, value, contentType)
 	if err != nil {
-		return WriteString(w, http.StatusInternalServerError, err.Error())
+		return writeEncodeError(w, err)
 	}
 	w.Header().Set(framework.HEADER_ContentType, contentType)
 	w.WriteHeader(status)
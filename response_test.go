@@ -0,0 +1,104 @@
+package mirango
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mirango/errors"
+)
+
+func TestWriteEncodeError(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *Response) error
+	}{
+		{"json", func(w *Response) error { return w.WriteAsJson(200, make(chan int)) }},
+		{"xml", func(w *Response) error { return w.WriteAsXml(200, make(chan int), true) }},
+		{"entity", func(w *Response) error { return w.WriteEntity(200, struct{}{}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := NewResponse(rec, nil)
+			w.encoding = "application/unknown"
+			if err := tt.write(w); err == nil {
+				t.Error("no error")
+			}
+			if rec.Code != 500 || rec.Body.Len() != 0 {
+				t.Errorf("got %d %q", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	large := strings.Repeat("a", 2048)
+	tests := []struct {
+		name    string
+		handler func(c *Context) interface{}
+		status  int
+		body    string
+	}{
+		{"nil", func(c *Context) interface{} { return nil }, 204, ""},
+		{"string", func(c *Context) interface{} { return "ok" }, 200, "ok"},
+		{"written body", func(c *Context) interface{} {
+			c.Write([]byte(large))
+			return nil
+		}, 200, large},
+		{"written small body", func(c *Context) interface{} {
+			c.Write([]byte("ok"))
+			return nil
+		}, 200, "ok"},
+		{"written status", func(c *Context) interface{} {
+			c.WriteHeader(201)
+			return nil
+		}, 201, ""},
+		{"written status and body", func(c *Context) interface{} {
+			c.WriteHeader(202)
+			c.Write([]byte(large))
+			return nil
+		}, 202, large},
+		{"responder", func(c *Context) interface{} { return Respond(202, "ok") }, 202, "ok"},
+		{"responder without body", func(c *Context) interface{} { return Respond(201, nil) }, 201, ""},
+		{"no content", func(c *Context) interface{} { return NoContent() }, 204, ""},
+		{"error", func(c *Context) interface{} { return errors.New(409, "conflict") }, 409, ""},
+	}
+	for _, compressed := range []bool{false, true} {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				m := New("/")
+				if compressed {
+					m.Compression(nil)
+				}
+				m.Branch("x").GET(tt.handler)
+				m.Prepare()
+
+				r := httptest.NewRequest("GET", "/x", nil)
+				r.Header.Set("Accept-Encoding", "gzip")
+				rec := httptest.NewRecorder()
+				m.ServeHTTP(rec, r)
+				if rec.Code != tt.status {
+					t.Errorf("status %d, want %d", rec.Code, tt.status)
+				}
+				if tt.body == "" {
+					return
+				}
+				var body io.Reader = rec.Body
+				if rec.Header().Get("Content-Encoding") == "gzip" {
+					zr, err := gzip.NewReader(rec.Body)
+					if err != nil {
+						t.Fatal(err)
+					}
+					body = zr
+				}
+				b, _ := io.ReadAll(body)
+				if string(b) != tt.body {
+					t.Errorf("body %q, want %q", b, tt.body)
+				}
+			})
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package mirango
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/mirango/errors"
@@ -25,9 +27,13 @@ type Route struct {
 	notFoundHandler         Handler
 	methodNotAllowedHandler Handler
 	panicHandler            Handler
+	problemRenderer         ProblemRenderer
 
 	presets Presets
 
+	validationMode validationMode
+	pagination     *Pagination
+
 	returnsOnly bool
 	acceptsOnly bool
 	schemesOnly bool
@@ -152,7 +158,10 @@ func (r *Route) Clone() *Route {
 	route.notFoundHandler = r.notFoundHandler
 	route.methodNotAllowedHandler = r.methodNotAllowedHandler
 	route.panicHandler = r.panicHandler
+	route.problemRenderer = r.problemRenderer
 	route.presets = r.presets
+	route.validationMode = r.validationMode
+	route.pagination = r.pagination
 	route.returnsOnly = r.returnsOnly
 	route.acceptsOnly = r.acceptsOnly
 	route.schemesOnly = r.schemesOnly
@@ -237,6 +246,20 @@ func (r *Route) GetPanicHandler() Handler {
 	return r.panicHandler
 }
 
+func (r *Route) GetProblemRenderer() ProblemRenderer {
+	if r.parent != nil && r.problemRenderer == nil {
+		return r.parent.GetProblemRenderer()
+	}
+	return r.problemRenderer
+}
+
+// ProblemRenderer makes the errors of the Route and its sub-routes render as
+// RFC 7807 problems.
+func (r *Route) ProblemRenderer(pr ProblemRenderer) *Route {
+	r.problemRenderer = pr
+	return r
+}
+
 func (r *Route) NotFoundHandler(h interface{}) *Route {
 	handler, err := handler(h)
 	if err != nil {
@@ -264,8 +287,72 @@ func (r *Route) PanicHandler(h interface{}) *Route {
 	return r
 }
 
+// BuildPath returns the full path of the Route with its params replaced by
+// the given values, in order, or by name if a single map is given.
 func (r *Route) BuildPath(v ...interface{}) string {
-	return ""
+	var named map[string]interface{}
+	if len(v) == 1 {
+		switch t := v[0].(type) {
+		case map[string]interface{}:
+			named = t
+		case map[string]string:
+			named = map[string]interface{}{}
+			for k, s := range t {
+				named[k] = s
+			}
+		}
+	}
+
+	slices := splitPath(r.GetFullPath())
+	_, names, indices, typs := processPath(slices)
+	n := 0
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		var value interface{}
+		if named != nil {
+			value = named[name]
+		} else if n < len(v) {
+			value = v[n]
+			n++
+		}
+		str := ""
+		if value != nil {
+			str = r.paramType(name).format(value)
+		}
+		if typs[i] == 1 {
+			segs := strings.Split(str, "/")
+			for j, seg := range segs {
+				segs[j] = url.PathEscape(seg)
+			}
+			str = strings.Join(segs, "/")
+		} else {
+			str = url.PathEscape(str)
+		}
+		slices[i] = slices[i][:indices[i]] + str
+	}
+	return "/" + strings.Join(slices, "/")
+}
+
+// paramType returns the ValueType of the param with the given name, declared
+// on the Route, its operations or its parents, or nil.
+func (r *Route) paramType(name string) *ValueType {
+	if r.mirango == nil {
+		return nil
+	}
+	for rt := r; rt != nil; rt = rt.parent {
+		params := []*Params{rt.params}
+		for _, o := range rt.operations.GetAll() {
+			params = append(params, o.params)
+		}
+		for _, ps := range params {
+			if p := ps.Get(name); p != nil && p.typeName != "" {
+				return r.mirango.types.Get(p.typeName)
+			}
+		}
+	}
+	return nil
 }
 
 func (r *Route) GetPath() string {
@@ -383,6 +470,12 @@ func (r *Route) BodyParam(name string) *Param {
 	return p
 }
 
+func (r *Route) CookieParam(name string) *Param {
+	p := CookieParam(name)
+	r.params.Append(p)
+	return p
+}
+
 func (r *Route) Operations(ops ...*Operation) *Route {
 	for _, o := range ops {
 		o = o.Clone()
@@ -430,6 +523,26 @@ func (r *Route) ReturnsOnly(returns ...string) *Route {
 	return r
 }
 
+// ValidationMode sets how the params of the operations of the Route are
+// validated, unless the operations set their own mode.
+func (r *Route) ValidationMode(mode validationMode) *Route {
+	r.validationMode = mode
+	return r
+}
+
+func (r *Route) GetValidationMode() validationMode {
+	if r.validationMode == validateInherit {
+		return VALIDATE_ALL
+	}
+	return r.validationMode
+}
+
+func (r *Route) getValidationMode() {
+	if r.validationMode == validateInherit && r.parent != nil {
+		r.validationMode = r.parent.validationMode
+	}
+}
+
 func (r *Route) With(mw ...interface{}) *Route {
 	for i := 0; i < len(mw); i++ {
 		switch t := mw[i].(type) {
@@ -515,12 +628,9 @@ func (r *Route) ServeHTTP(c *Context, res result) interface{} {
 
 	o := r.operations.GetByMethod(c.Request.Request.Method)
 
-	err := setPathParams(c, o.params, res)
-	if err != nil {
-		return err
-	}
-
 	if o == nil {
+		var err *errors.Error
+		addVary(c.Response.Header(), "Accept")
 		c.Response.encoding, err = getEncodingFromAccept(r.returns, c.Request)
 		if err != nil {
 			return err
@@ -531,9 +641,23 @@ func (r *Route) ServeHTTP(c *Context, res result) interface{} {
 		}
 		return mnaHandler.ServeHTTP(c)
 	}
+
+	err := setPathParams(c, o.params, res)
+	if err != nil {
+		return err
+	}
+
 	return o.ServeHTTP(c)
 }
 
+// walk calls f for the Route and all its sub-routes.
+func (r *Route) walk(f func(*Route)) {
+	f(r)
+	for _, cr := range r.node.getChildRoutes() {
+		cr.walk(f)
+	}
+}
+
 func (r *Route) Apply(p ...Preset) {
 	r.presets = append(r.presets, p...)
 }
@@ -545,6 +669,7 @@ func (r *Route) finalize() {
 	r.getAllReturns()
 	r.getAllMiddleware()
 	r.getAllPresets()
+	r.getValidationMode()
 	for _, cr := range r.node.getChildRoutes() {
 		cr.finalize()
 	}
@@ -552,7 +677,6 @@ func (r *Route) finalize() {
 	r.params = nil
 	r.schemes = nil
 	r.accepts = nil
-	r.returns = nil
 	r.middleware = nil
 	r.presets = nil
 }
@@ -565,13 +689,14 @@ func setPathParams(c *Context, params *Params, res result) *errors.Error {
 	if res.node.paramsCount > 0 {
 		for i := 0; i < res.node.paramsCount; i++ {
 			name, value = res.paramByIndex(i)
-			if name == "" || value == "" {
-				return nil //error
+			if name == "" {
+				continue
 			}
 			p = params.Get(name)
 			if p == nil || !p.IsIn(IN_PATH) {
-				return nil //error
+				return errors.New(http.StatusInternalServerError, fmt.Sprintf("path param %s is not declared", name))
 			}
+			c.pathValues[name] = value
 			pv = validation.NewValue(p.name, value, "path", p.GetAs())
 			c.input.Append(pv)
 		}
@@ -0,0 +1,203 @@
+package mirango
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mirango/framework"
+)
+
+const MIME_EVENT_STREAM = "text/event-stream"
+
+// ErrEventStreamClosed is returned when writing to a closed EventWriter.
+var ErrEventStreamClosed = fmt.Errorf("event stream closed")
+
+// EventWriter writes Server-Sent Events. It is safe for concurrent use, but
+// must not be used after the handler returns, as it is closed then.
+type EventWriter struct {
+	w           *Response
+	mu          sync.Mutex
+	lastEventID string
+	closed      chan struct{}
+	closeOnce   sync.Once
+	heartbeats  sync.WaitGroup
+}
+
+// SSE starts an event stream on the response. Data of the events is encoded
+// with the encoder of the negotiated encoding, or as JSON. The stream ends
+// when the handler returns.
+func (w *Response) SSE() *EventWriter {
+	w.disableCompression()
+	h := w.Header()
+	h.Set(framework.HEADER_ContentType, MIME_EVENT_STREAM)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	w.Flush()
+
+	e := &EventWriter{
+		w:      w,
+		closed: make(chan struct{}),
+	}
+	if w.request != nil {
+		e.lastEventID = w.request.Header.Get("Last-Event-ID")
+	}
+	go func() {
+		select {
+		case <-w.done():
+			e.stop()
+		case <-e.closed:
+		}
+	}()
+	w.events = append(w.events, e)
+	return e
+}
+
+// closeEvents closes the event writers of the response once the handler
+// returned.
+func (w *Response) closeEvents() {
+	for _, e := range w.events {
+		e.Close()
+	}
+	w.events = nil
+}
+
+// LastEventID returns the id of the last event received by the client
+// before it reconnected, to resume the stream from.
+func (e *EventWriter) LastEventID() string {
+	return e.lastEventID
+}
+
+// Done returns a channel closed when the client goes away or the writer is
+// closed.
+func (e *EventWriter) Done() <-chan struct{} {
+	return e.closed
+}
+
+// Close ends the stream. It waits for the heartbeat and the events being
+// written, so that nothing is written to the response after it returns.
+func (e *EventWriter) Close() {
+	e.stop()
+	e.mu.Lock()
+	e.mu.Unlock()
+	e.heartbeats.Wait()
+}
+
+func (e *EventWriter) stop() {
+	e.closeOnce.Do(func() {
+		close(e.closed)
+	})
+}
+
+func (e *EventWriter) write(b []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.closed:
+		if err := e.w.doneErr(); err != nil {
+			return err
+		}
+		return ErrEventStreamClosed
+	default:
+	}
+	_, err := e.w.Write(b)
+	if err != nil {
+		e.stop()
+		return err
+	}
+	e.w.Flush()
+	return nil
+}
+
+// Send writes an event. Empty event and id fields are omitted. Strings and
+// byte slices are sent as is, other data is encoded.
+func (e *EventWriter) Send(event string, id string, data interface{}) error {
+	var b []byte
+	switch t := data.(type) {
+	case nil:
+	case string:
+		b = []byte(t)
+	case []byte:
+		b = t
+	default:
+		encoding := e.w.encoding
+		if encoding == "" || encoding == MIME_EVENT_STREAM {
+			encoding = framework.MIME_JSON
+		}
+		var err error
+		b, err = encode(e.w, data, encoding)
+		if err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	if id != "" {
+		buf.WriteString("id: " + sseField(id) + "\n")
+	}
+	if event != "" {
+		buf.WriteString("event: " + sseField(event) + "\n")
+	}
+	b = bytes.TrimRight(b, "\r\n")
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimRight(line, "\r"))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return e.write(buf.Bytes())
+}
+
+// Comment writes a comment, which clients ignore. It can be used to keep
+// the connection alive.
+func (e *EventWriter) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return e.write(buf.Bytes())
+}
+
+// Retry tells the client how long to wait before reconnecting.
+func (e *EventWriter) Retry(d time.Duration) error {
+	return e.write([]byte("retry: " + strconv.FormatInt(int64(d/time.Millisecond), 10) + "\n\n"))
+}
+
+// Heartbeat writes a comment every d until the writer is closed.
+func (e *EventWriter) Heartbeat(d time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	select {
+	case <-e.closed:
+		return
+	default:
+	}
+	e.heartbeats.Add(1)
+	go func() {
+		defer e.heartbeats.Done()
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-e.closed:
+				return
+			case <-t.C:
+				if e.Comment("heartbeat") != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// sseField removes the line breaks of a field, which would end it.
+func sseField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
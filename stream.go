@@ -0,0 +1,286 @@
+package mirango
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mirango/framework"
+)
+
+const (
+	MIME_NDJSON   = "application/x-ndjson"
+	MIME_JSON_SEQ = "application/json-seq"
+)
+
+type streamFormat int
+
+const (
+	streamNDJSON streamFormat = iota
+	streamJSONSeq
+	streamXML
+	streamCSV
+	streamMsgpack
+)
+
+// streamWriter writes the items of a stream, flushing after each one.
+type streamWriter struct {
+	w       *Response
+	status  int
+	format  streamFormat
+	started bool
+	columns *csvColumns
+}
+
+func (w *Response) newStreamWriter(status int, format streamFormat) *streamWriter {
+	return &streamWriter{
+		w:      w,
+		status: status,
+		format: format,
+	}
+}
+
+// streamFormatOf returns the stream format for the negotiated encoding.
+func streamFormatOf(encoding string) streamFormat {
+	_, subtype := parseMediaType(encoding)
+	switch {
+	case encoding == MIME_JSON_SEQ:
+		return streamJSONSeq
+	case subtype == "xml" || strings.HasSuffix(subtype, "+xml"):
+		return streamXML
+	case subtype == "csv":
+		return streamCSV
+	case subtype == "msgpack" || subtype == "x-msgpack":
+		return streamMsgpack
+	}
+	return streamNDJSON
+}
+
+func (s *streamWriter) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	h := s.w.Header()
+	switch s.format {
+	case streamNDJSON:
+		h.Set(framework.HEADER_ContentType, MIME_NDJSON)
+	case streamJSONSeq:
+		h.Set(framework.HEADER_ContentType, MIME_JSON_SEQ)
+	case streamXML:
+		h.Set(framework.HEADER_ContentType, framework.MIME_XML)
+	case streamCSV:
+		h.Set(framework.HEADER_ContentType, MIME_CSV)
+	case streamMsgpack:
+		h.Set(framework.HEADER_ContentType, MIME_MSGPACK)
+	}
+	h.Del("Content-Length")
+	s.w.WriteHeader(s.status)
+	if s.format == streamXML {
+		_, err := s.w.Write([]byte(xml.Header + "<items>\n"))
+		return err
+	}
+	return nil
+}
+
+func (s *streamWriter) write(item interface{}) error {
+	switch s.format {
+	case streamCSV:
+		return s.writeCSV(item)
+	case streamMsgpack:
+		b, err := encode(s.w, item, MIME_MSGPACK)
+		if err != nil {
+			return err
+		}
+		return s.writeRaw(b)
+	}
+
+	var b []byte
+	var err error
+	if s.format == streamXML {
+		b, err = encode(s.w, item, framework.MIME_XML)
+		b = bytes.TrimPrefix(b, []byte(xml.Header))
+	} else {
+		b, err = encode(s.w, item, framework.MIME_JSON)
+	}
+	if err != nil {
+		return err
+	}
+	err = s.start()
+	if err != nil {
+		return err
+	}
+	b = bytes.TrimRight(b, "\n")
+	if s.format == streamJSONSeq {
+		b = append([]byte{0x1e}, b...)
+	}
+	_, err = s.w.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+func (s *streamWriter) writeRaw(b []byte) error {
+	err := s.start()
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(b)
+	if err != nil {
+		return err
+	}
+	s.w.Flush()
+	return nil
+}
+
+// writeCSV writes an item, or each of its elements, as CSV rows, preceded
+// by the header row for the first item.
+func (s *streamWriter) writeCSV(item interface{}) error {
+	rows := csvRows(reflect.ValueOf(item))
+	if len(rows) == 0 {
+		return nil
+	}
+	cc, ok := s.w.encoders.Get(MIME_CSV).(CSVCodec)
+	if !ok {
+		cc = CSVCodec{}
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Comma = cc.comma()
+	if s.columns == nil {
+		cols, err := newCSVColumns(csvRowType(reflect.TypeOf(item)), rows)
+		if err != nil {
+			return err
+		}
+		s.columns = cols
+		err = cw.Write(cols.names)
+		if err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		err := cw.Write(s.columns.record(row))
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return s.writeRaw(buf.Bytes())
+}
+
+func (s *streamWriter) end() error {
+	err := s.start()
+	if err != nil {
+		return err
+	}
+	if s.format == streamXML {
+		_, err = s.w.Write([]byte("</items>\n"))
+		s.w.Flush()
+	}
+	return err
+}
+
+// done returns a channel closed when the client goes away, or nil if the
+// request context is unknown.
+func (w *Response) done() <-chan struct{} {
+	if w.ctx == nil {
+		return nil
+	}
+	return w.ctx.Done()
+}
+
+func (w *Response) doneErr() error {
+	if w.ctx == nil {
+		return nil
+	}
+	return w.ctx.Err()
+}
+
+// StreamChan writes the items received from ch, which must be a receive
+// channel, as they arrive, until ch is closed or the client goes away.
+// Items are written as NDJSON, JSON text sequences, an XML list, CSV rows or
+// MessagePack values depending on the negotiated encoding.
+func (w *Response) StreamChan(status int, ch interface{}) error {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir()&reflect.RecvDir == 0 {
+		return fmt.Errorf("cannot stream from %T", ch)
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: v},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(w.done())},
+	}
+	s := w.newStreamWriter(status, streamFormatOf(w.encoding))
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 1 {
+			return w.doneErr()
+		}
+		if !ok {
+			return s.end()
+		}
+		err := s.write(item.Interface())
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// StreamIter writes the items returned by next until it returns false or an
+// error, or the client goes away.
+func (w *Response) StreamIter(status int, next func() (interface{}, bool, error)) error {
+	s := w.newStreamWriter(status, streamFormatOf(w.encoding))
+	done := w.done()
+	for {
+		select {
+		case <-done:
+			return w.doneErr()
+		default:
+		}
+		item, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return s.end()
+		}
+		err = s.write(item)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// streamPoll calls f every d until it asks to stop or the client goes away.
+func (w *Response) streamPoll(s *streamWriter, d time.Duration, f func(int64) (interface{}, bool)) error {
+	var i int64 = 0
+	done := w.done()
+	for {
+		e, stop := f(i)
+		if e != nil {
+			err := s.write(e)
+			if err != nil {
+				return err
+			}
+			i++
+		}
+		if stop {
+			return s.end()
+		}
+		select {
+		case <-done:
+			return w.doneErr()
+		case <-time.After(d):
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package mirango
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mirango/framework"
+)
+
+const MIME_HTML = "text/html"
+
+// Templates renders the operations returning text/html with html/template.
+//
+// A page is rendered as the "content" template inside its layout, which
+// includes it with {{template "content" .}}. Pages can redefine the blocks
+// of their layout. The partials are parsed with every page and named after
+// their path without extension, such as "partials/user_card".
+//
+// The url function builds the path of a named operation, such as
+// {{url "get_user" .ID}}.
+type Templates struct {
+	// Ext is the extension of the template files, ".html" by default.
+	Ext string
+	// Layout is the layout of the pages, unless set by their operation.
+	Layout string
+	// Partials is the directory of the partials, "partials" by default.
+	Partials string
+	// Reload parses the templates on every render instead of once, to see
+	// changes without restarting during development.
+	Reload bool
+	// Funcs are added to the functions available to the templates.
+	Funcs template.FuncMap
+
+	fsys    fs.FS
+	mirango *Mirango
+	mu      sync.RWMutex
+	cache   map[string]*template.Template
+}
+
+// NewTemplates returns Templates reading the files of a directory.
+func NewTemplates(dir string) *Templates {
+	return NewTemplatesFS(os.DirFS(dir))
+}
+
+// NewTemplatesFS returns Templates reading the files of fsys, such as an
+// embed.FS.
+func NewTemplatesFS(fsys fs.FS) *Templates {
+	return &Templates{
+		Ext:      ".html",
+		Partials: "partials",
+		fsys:     fsys,
+		cache:    map[string]*template.Template{},
+	}
+}
+
+// Templates sets the templates rendering the operations with a template.
+func (m *Mirango) Templates(t *Templates) {
+	t.mirango = m
+	m.templates = t
+}
+
+// Template sets the template rendering the data returned by the handler
+// when text/html is negotiated, and adds text/html to the returned types.
+func (o *Operation) Template(name string) *Operation {
+	o.template = name
+	return o.Returns(MIME_HTML)
+}
+
+// Layout sets the layout of the template of the Operation, or no layout if
+// empty.
+func (o *Operation) Layout(name string) *Operation {
+	o.layout = name
+	o.hasLayout = true
+	return o
+}
+
+func (o *Operation) GetTemplate() string {
+	return o.template
+}
+
+// Render executes the page in the layout, or alone if the layout is empty.
+func (t *Templates) Render(w io.Writer, page string, layout string, data interface{}) error {
+	tpl, err := t.load(page, layout)
+	if err != nil {
+		return err
+	}
+	name := "content"
+	if layout != "" {
+		name = layout
+	}
+	return tpl.ExecuteTemplate(w, name, data)
+}
+
+func (t *Templates) load(page string, layout string) (*template.Template, error) {
+	key := layout + "\x00" + page
+	if !t.Reload {
+		t.mu.RLock()
+		tpl, ok := t.cache[key]
+		t.mu.RUnlock()
+		if ok {
+			return tpl, nil
+		}
+	}
+
+	tpl := template.New("").Funcs(t.funcs())
+	err := t.parsePartials(tpl)
+	if err != nil {
+		return nil, err
+	}
+	if layout != "" {
+		err = t.parse(tpl, layout, layout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = t.parse(tpl, "content", page)
+	if err != nil {
+		return nil, err
+	}
+
+	if !t.Reload {
+		t.mu.Lock()
+		t.cache[key] = tpl
+		t.mu.Unlock()
+	}
+	return tpl, nil
+}
+
+func (t *Templates) parse(tpl *template.Template, name string, file string) error {
+	b, err := fs.ReadFile(t.fsys, file+t.Ext)
+	if err != nil {
+		return err
+	}
+	_, err = tpl.New(name).Parse(string(b))
+	return err
+}
+
+func (t *Templates) parsePartials(tpl *template.Template) error {
+	if t.Partials == "" {
+		return nil
+	}
+	if _, err := fs.Stat(t.fsys, t.Partials); err != nil {
+		return nil
+	}
+	return fs.WalkDir(t.fsys, t.Partials, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != t.Ext {
+			return nil
+		}
+		return t.parse(tpl, strings.TrimSuffix(p, t.Ext), strings.TrimSuffix(p, t.Ext))
+	})
+}
+
+func (t *Templates) funcs() template.FuncMap {
+	funcs := template.FuncMap{
+		"url": func(name string, v ...interface{}) (string, error) {
+			if t.mirango == nil {
+				return "", fmt.Errorf("templates are not set on Mirango")
+			}
+			return t.mirango.URL(name, v...)
+		},
+	}
+	for name, f := range t.Funcs {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+// URL returns the path of the operation with the given name, built with the
+// given param values.
+func (m *Mirango) URL(name string, v ...interface{}) (string, error) {
+	o, ok := m.operations[name]
+	if !ok {
+		return "", fmt.Errorf("no operation named %q", name)
+	}
+	return o.BuildPath(v...), nil
+}
+
+// renderTemplate renders data with the template of the operation if it has
+// one, text/html was negotiated and the status has content. It reports
+// whether it did.
+func (w *Response) renderTemplate(c *Context, status int, data interface{}) (bool, error) {
+	o := c.operation
+	if o == nil || o.template == "" || c.templates == nil {
+		return false, nil
+	}
+	if status != 0 && (status < 200 || status >= 300 || status == http.StatusNoContent) {
+		return false, nil
+	}
+	if strings.TrimSpace(strings.Split(w.encoding, ";")[0]) != MIME_HTML {
+		return false, nil
+	}
+
+	layout := c.templates.Layout
+	if o.hasLayout {
+		layout = o.layout
+	}
+	var buf bytes.Buffer
+	err := c.templates.Render(&buf, o.template, layout, data)
+	if err != nil {
+		return true, err
+	}
+	if w.Header().Get(framework.HEADER_ContentType) == "" {
+		w.Header().Set(framework.HEADER_ContentType, "text/html; charset=utf-8")
+	}
+	if w.notModified(status, buf.Bytes()) {
+		return true, nil
+	}
+	w.WriteHeader(status)
+	_, err = w.Write(buf.Bytes())
+	return true, err
+}
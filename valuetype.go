@@ -0,0 +1,209 @@
+package mirango
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// ValueType is a named type that params can be declared as with
+// Param.AsType. Format formats values of the type in built paths and
+// pagination links. It may be given values of other types, that it formats
+// with fmt.Sprint.
+type ValueType struct {
+	Name   string
+	Parse  func(string) (interface{}, error)
+	Format func(interface{}) string
+}
+
+// format formats v with the Format of the type, or with fmt.Sprint if there
+// is none. Strings are returned as is.
+func (t *ValueType) format(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	if t != nil && t.Format != nil {
+		return t.Format(v)
+	}
+	return fmt.Sprint(v)
+}
+
+// ValueTypes is a registry of value types, keyed by name.
+type ValueTypes struct {
+	types map[string]*ValueType
+}
+
+func NewValueTypes() *ValueTypes {
+	vts := &ValueTypes{
+		types: map[string]*ValueType{},
+	}
+	vts.Append(DefaultValueTypes...)
+	return vts
+}
+
+// Append registers the given value types, replacing the ones with the same
+// names.
+func (vts *ValueTypes) Append(types ...*ValueType) {
+	for _, t := range types {
+		if t == nil || t.Name == "" {
+			panic("Detected a value type without a name.")
+		}
+		if t.Parse == nil {
+			panic(fmt.Sprintf("Detected a value type without a parse function: \"%s\".", t.Name))
+		}
+		vts.types[t.Name] = t
+	}
+}
+
+func (vts *ValueTypes) Get(name string) *ValueType {
+	return vts.types[name]
+}
+
+// DefaultValueTypes are registered on every Mirango.
+var DefaultValueTypes = []*ValueType{
+	{
+		Name:   "uuid",
+		Parse:  func(s string) (interface{}, error) { return ParseUUID(s) },
+		Format: func(v interface{}) string { return fmt.Sprint(v) },
+	},
+	{
+		Name:  "time",
+		Parse: func(s string) (interface{}, error) { return time.Parse(time.RFC3339, s) },
+		Format: func(v interface{}) string {
+			if t, ok := v.(time.Time); ok {
+				return t.Format(time.RFC3339)
+			}
+			return fmt.Sprint(v)
+		},
+	},
+	{
+		Name:   "duration",
+		Parse:  func(s string) (interface{}, error) { return time.ParseDuration(s) },
+		Format: func(v interface{}) string { return fmt.Sprint(v) },
+	},
+	{
+		Name: "decimal",
+		Parse: func(s string) (interface{}, error) {
+			r, ok := new(big.Rat).SetString(s)
+			if !ok {
+				return nil, fmt.Errorf("invalid decimal: %q", s)
+			}
+			return r, nil
+		},
+		Format: func(v interface{}) string {
+			if r, ok := v.(*big.Rat); ok {
+				return r.FloatString(10)
+			}
+			return fmt.Sprint(v)
+		},
+	},
+	{
+		Name: "ip",
+		Parse: func(s string) (interface{}, error) {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %q", s)
+			}
+			return ip, nil
+		},
+		Format: func(v interface{}) string { return fmt.Sprint(v) },
+	},
+}
+
+// UUID is the value of params declared as "uuid".
+type UUID [16]byte
+
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if !uuidRegexp.MatchString(s) {
+		return u, fmt.Errorf("invalid UUID: %q", s)
+	}
+	_, err := hex.Decode(u[:], []byte(strings.Replace(s, "-", "", -1)))
+	return u, err
+}
+
+func (u UUID) String() string {
+	s := hex.EncodeToString(u[:])
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:]
+}
+
+// parseValues parses the values of a param declared with a named value type.
+func parseValues(t *ValueType, p *Param, values []string) (interface{}, error) {
+	if !p.IsMultiple() {
+		return t.Parse(values[0])
+	}
+	parsed := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		pv, err := t.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, pv)
+	}
+	return parsed, nil
+}
+
+// parseDeepValues parses the members of a deep object, keeping its maps and
+// slices.
+func parseDeepValues(t *ValueType, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return t.Parse(v)
+	case []interface{}:
+		parsed := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			pe, err := parseDeepValues(t, e)
+			if err != nil {
+				return nil, err
+			}
+			parsed = append(parsed, pe)
+		}
+		return parsed, nil
+	case map[string]interface{}:
+		parsed := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			pe, err := parseDeepValues(t, e)
+			if err != nil {
+				return nil, err
+			}
+			parsed[k] = pe
+		}
+		return parsed, nil
+	}
+	return v, nil
+}
+
+// ParamValue returns the parsed value of a param declared with a named value
+// type. The value of a multiple param is a []interface{}, and that of a deep
+// object has the maps and slices of the object.
+func (r *Request) ParamValue(name string) interface{} {
+	return r.parsed[name]
+}
+
+func (r *Request) ParamUUID(name string) (UUID, bool) {
+	v, ok := r.parsed[name].(UUID)
+	return v, ok
+}
+
+func (r *Request) ParamTime(name string) (time.Time, bool) {
+	v, ok := r.parsed[name].(time.Time)
+	return v, ok
+}
+
+func (r *Request) ParamDuration(name string) (time.Duration, bool) {
+	v, ok := r.parsed[name].(time.Duration)
+	return v, ok
+}
+
+func (r *Request) ParamDecimal(name string) (*big.Rat, bool) {
+	v, ok := r.parsed[name].(*big.Rat)
+	return v, ok
+}
+
+func (r *Request) ParamIP(name string) (net.IP, bool) {
+	v, ok := r.parsed[name].(net.IP)
+	return v, ok
+}
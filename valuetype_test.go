@@ -0,0 +1,68 @@
+package mirango
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mirango/framework"
+)
+
+func TestBuildPathFormatsValueTypes(t *testing.T) {
+	day := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	u, _ := ParseUUID("0123abcd-0000-4000-8000-00000000cdef")
+
+	m := New("/")
+	events := m.Branch("events/:day")
+	events.Params(PathParam("day").AsType("time"))
+	o := events.Branch("items/:id").GET(func(c *Context) interface{} { return nil })
+	o.Params(PathParam("id").AsType("uuid"))
+	m.Prepare()
+
+	tests := []struct {
+		name string
+		v    []interface{}
+		want string
+	}{
+		{"typed values", []interface{}{day, u}, "/events/2020-01-02T03:04:05Z/items/0123abcd-0000-4000-8000-00000000cdef"},
+		{"strings", []interface{}{"today", "x"}, "/events/today/items/x"},
+		{"other types", []interface{}{1, 2}, "/events/1/items/2"},
+		{"named", []interface{}{map[string]interface{}{"id": u, "day": day}}, "/events/2020-01-02T03:04:05Z/items/0123abcd-0000-4000-8000-00000000cdef"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := o.BuildPath(tt.v...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamAs(t *testing.T) {
+	p := QueryParam("a").AsType("uuid")
+	if p.GetTypeName() != "uuid" || p.GetAs() != framework.TYPE_STRING {
+		t.Errorf("AsType: %q %v", p.GetTypeName(), p.GetAs())
+	}
+	p.As(framework.TYPE_INT)
+	if p.GetTypeName() != "" || p.GetAs() != framework.TYPE_INT {
+		t.Errorf("As: %q %v", p.GetTypeName(), p.GetAs())
+	}
+}
+
+func TestPageCursorFormat(t *testing.T) {
+	next := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := New("/")
+	m.Branch("events").GET(func(c *Context) interface{} {
+		c.Page().SetCursors(next, nil)
+		return []int{1}
+	}).Paginate(&Pagination{DefaultLimit: 10, Cursor: true}).Params(QueryParam("cursor").AsType("time"))
+	m.Prepare()
+
+	r := httptest.NewRequest("GET", "/events", nil)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	want := `</events?limit=10>; rel="first", </events?cursor=2020-01-02T03%3A04%3A05Z&limit=10>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,500 @@
+package mirango
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mirango/errors"
+)
+
+// WebSocket message types.
+const (
+	WS_TEXT   = 1
+	WS_BINARY = 2
+)
+
+// WebSocket close codes, as defined by RFC 6455.
+const (
+	WS_CLOSE_NORMAL           = 1000
+	WS_CLOSE_GOING_AWAY       = 1001
+	WS_CLOSE_PROTOCOL_ERROR   = 1002
+	WS_CLOSE_UNSUPPORTED_DATA = 1003
+	WS_CLOSE_NO_STATUS        = 1005
+	WS_CLOSE_ABNORMAL         = 1006
+	WS_CLOSE_INVALID_PAYLOAD  = 1007
+	WS_CLOSE_POLICY_VIOLATION = 1008
+	WS_CLOSE_MESSAGE_TOO_BIG  = 1009
+	WS_CLOSE_INTERNAL_ERROR   = 1011
+)
+
+const (
+	wsOpContinuation          = 0
+	wsOpClose                 = 8
+	wsOpPing                  = 9
+	wsOpPong                  = 10
+	wsMaxControlPayload       = 125
+	wsGUID                    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsDefaultReadLimit  int64 = 32 << 20
+)
+
+// WebSocketCloseError is returned by ReadMessage when the peer closes the
+// connection.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+// Upgrader performs the WebSocket handshake of an operation.
+type Upgrader struct {
+	// Subprotocols are the supported subprotocols, in order of preference.
+	Subprotocols []string
+	// ReadLimit is the maximum size of a message, 32MB by default.
+	ReadLimit int64
+	// PingInterval, if set, is the interval at which pings are sent.
+	PingInterval time.Duration
+	// CheckOrigin returns true if the origin of the request is allowed. By
+	// default the Origin header, if set, has to match the Host header.
+	CheckOrigin func(*http.Request) bool
+}
+
+var DefaultUpgrader = &Upgrader{}
+
+// WebSocket returns a GET operation that upgrades the connection with the
+// DefaultUpgrader and passes it to h.
+func WebSocket(h func(*Context, *WebSocketConn)) *Operation {
+	return DefaultUpgrader.Operation(h)
+}
+
+// Operation returns a GET operation that upgrades the connection and passes
+// it to h. The params and middleware of the operation run before the
+// handshake.
+func (u *Upgrader) Operation(h func(*Context, *WebSocketConn)) *Operation {
+	if h == nil {
+		panic("websocket handler is nil")
+	}
+	return NewOperation(func(c *Context) interface{} {
+		conn, err := u.Upgrade(c)
+		if err != nil {
+			return err
+		}
+		defer conn.close()
+		c.End()
+		h(c, conn)
+		return nil
+	}).Methods("GET")
+}
+
+func (r *Route) WebSocket(h func(*Context, *WebSocketConn)) *Operation {
+	return r.WebSocketNested(h, nil)
+}
+
+func (r *Route) WebSocketNested(h func(*Context, *WebSocketConn), cb func(*Operation)) *Operation {
+	o := WebSocket(h)
+	o.route = r
+	r.operations.Append(o)
+
+	if cb != nil {
+		cb(o)
+	}
+
+	return o
+}
+
+func headerContainsToken(h http.Header, name string, token string) bool {
+	for _, v := range splitHeaderValues(h.Values(name)) {
+		if strings.EqualFold(v, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// Upgrade performs the handshake and hijacks the connection. If the request
+// is not a valid WebSocket handshake it returns an error to render.
+func (u *Upgrader) Upgrade(c *Context) (*WebSocketConn, *errors.Error) {
+	r := c.Request.Request
+
+	if r.Method != "GET" ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, errors.New(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, errors.New(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if k, err := base64.StdEncoding.DecodeString(key); err != nil || len(k) != 16 {
+		return nil, errors.New(http.StatusBadRequest, "invalid websocket key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, errors.New(http.StatusForbidden, "origin not allowed")
+	}
+
+	subprotocol := ""
+	offered := splitHeaderValues(r.Header.Values("Sec-WebSocket-Protocol"))
+	for _, p := range u.Subprotocols {
+		if containsString(offered, p) {
+			subprotocol = p
+			break
+		}
+	}
+
+	netConn, rw, err := c.Response.Hijack()
+	if err != nil {
+		return nil, errors.New(http.StatusInternalServerError, err.Error())
+	}
+	// the deadlines of the server would end the connection
+	netConn.SetDeadline(time.Time{})
+
+	h := sha1.Sum([]byte(key + wsGUID))
+	res := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(h[:]) + "\r\n"
+	if subprotocol != "" {
+		res += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	res += "\r\n"
+	_, err = netConn.Write([]byte(res))
+	if err != nil {
+		netConn.Close()
+		return nil, errors.New(http.StatusInternalServerError, err.Error())
+	}
+
+	readLimit := u.ReadLimit
+	if readLimit <= 0 {
+		readLimit = wsDefaultReadLimit
+	}
+	conn := &WebSocketConn{
+		conn:        netConn,
+		br:          rw.Reader,
+		readLimit:   readLimit,
+		subprotocol: subprotocol,
+		closed:      make(chan struct{}),
+	}
+	if u.PingInterval > 0 {
+		go conn.keepAlive(u.PingInterval)
+	}
+	return conn, nil
+}
+
+// WebSocketConn is a message-level WebSocket connection. Writes are safe for
+// concurrent use, reads are not.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	wmu         sync.Mutex
+	readLimit   int64
+	subprotocol string
+	pongHandler func(string)
+	closeSent   bool
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// SetReadLimit sets the maximum size of a message. Larger messages close the
+// connection with WS_CLOSE_MESSAGE_TOO_BIG.
+func (c *WebSocketConn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetPongHandler sets the function called with the payload of each pong.
+func (c *WebSocketConn) SetPongHandler(h func(string)) {
+	c.pongHandler = h
+}
+
+func (c *WebSocketConn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Done returns a channel closed when the connection is closed.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *WebSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return &WebSocketCloseError{Code: WS_CLOSE_ABNORMAL, Reason: "close already sent"}
+	}
+	if opcode == wsOpClose {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	l := len(payload)
+	switch {
+	case l <= 125:
+		header[1] = byte(l)
+	case l <= 0xffff:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+	}
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// WriteMessage writes a WS_TEXT or WS_BINARY message.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WS_TEXT && messageType != WS_BINARY {
+		return fmt.Errorf("invalid websocket message type: %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+func (c *WebSocketConn) WriteText(text string) error {
+	return c.WriteMessage(WS_TEXT, []byte(text))
+}
+
+// WriteJSON writes v encoded as JSON in a text message.
+func (c *WebSocketConn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(WS_TEXT, b)
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *WebSocketConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > wsMaxControlPayload {
+		return fmt.Errorf("websocket control payload too long")
+	}
+	return c.writeFrame(wsOpPing, data)
+}
+
+// Close sends a close frame with the given code and reason, then closes the
+// connection.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	err := c.sendClose(code, reason)
+	c.close()
+	return err
+}
+
+func (c *WebSocketConn) sendClose(code int, reason string) error {
+	var payload []byte
+	if code != WS_CLOSE_NO_STATUS {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > wsMaxControlPayload {
+			payload = payload[:wsMaxControlPayload]
+		}
+	}
+	return c.writeFrame(wsOpClose, payload)
+}
+
+func (c *WebSocketConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
+
+// fail closes the connection with the given code and returns the matching
+// error.
+func (c *WebSocketConn) fail(code int, reason string) error {
+	c.sendClose(code, reason)
+	c.close()
+	return &WebSocketCloseError{Code: code, Reason: reason}
+}
+
+func (c *WebSocketConn) keepAlive(d time.Duration) {
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-t.C:
+			if c.Ping(nil) != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *WebSocketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var h [2]byte
+	_, err = io.ReadFull(c.br, h[:])
+	if err != nil {
+		return
+	}
+	fin = h[0]&0x80 != 0
+	if h[0]&0x70 != 0 {
+		err = c.fail(WS_CLOSE_PROTOCOL_ERROR, "reserved bits set")
+		return
+	}
+	opcode = h[0] & 0x0f
+	if h[1]&0x80 == 0 {
+		err = c.fail(WS_CLOSE_PROTOCOL_ERROR, "frame not masked")
+		return
+	}
+
+	l := int64(h[1] & 0x7f)
+	switch l {
+	case 126:
+		var b [2]byte
+		_, err = io.ReadFull(c.br, b[:])
+		l = int64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		_, err = io.ReadFull(c.br, b[:])
+		l = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	if err != nil {
+		return
+	}
+
+	if opcode >= wsOpClose {
+		if !fin || l > wsMaxControlPayload {
+			err = c.fail(WS_CLOSE_PROTOCOL_ERROR, "invalid control frame")
+			return
+		}
+	} else if l < 0 || l > c.readLimit {
+		err = c.fail(WS_CLOSE_MESSAGE_TOO_BIG, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(c.br, mask[:])
+	if err != nil {
+		return
+	}
+	payload = make([]byte, l)
+	_, err = io.ReadFull(c.br, payload)
+	if err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// ReadMessage reads the next WS_TEXT or WS_BINARY message. Pings are
+// answered and pongs are passed to the pong handler while reading. When the
+// peer closes the connection, the close is echoed and a
+// *WebSocketCloseError is returned.
+func (c *WebSocketConn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			if _, ok := err.(*WebSocketCloseError); !ok {
+				c.close()
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			err = c.writeFrame(wsOpPong, payload)
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			if c.pongHandler != nil {
+				c.pongHandler(string(payload))
+			}
+			continue
+		case wsOpClose:
+			code := WS_CLOSE_NO_STATUS
+			reason := ""
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				reason = string(payload[2:])
+			} else if len(payload) == 1 {
+				return 0, nil, c.fail(WS_CLOSE_PROTOCOL_ERROR, "invalid close payload")
+			}
+			c.sendClose(code, "")
+			c.close()
+			return 0, nil, &WebSocketCloseError{Code: code, Reason: reason}
+		case WS_TEXT, WS_BINARY:
+			if messageType != 0 {
+				return 0, nil, c.fail(WS_CLOSE_PROTOCOL_ERROR, "expected continuation frame")
+			}
+			messageType = int(opcode)
+			message = payload
+		case wsOpContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(WS_CLOSE_PROTOCOL_ERROR, "unexpected continuation frame")
+			}
+			if int64(len(message)+len(payload)) > c.readLimit {
+				return 0, nil, c.fail(WS_CLOSE_MESSAGE_TOO_BIG, "message too big")
+			}
+			message = append(message, payload...)
+		default:
+			return 0, nil, c.fail(WS_CLOSE_PROTOCOL_ERROR, "unknown opcode")
+		}
+
+		if fin {
+			if messageType == WS_TEXT && !utf8.Valid(message) {
+				return 0, nil, c.fail(WS_CLOSE_INVALID_PAYLOAD, "invalid utf-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}